@@ -1,15 +1,22 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/logging"
 )
 
 type Command struct {
 	Name        string
 	Usage       string
 	Description string
-	Execute     func(args []string) error
+	Execute     func(ctx context.Context, args []string) error
 }
 
 type CLI struct {
@@ -37,15 +44,45 @@ func (c *CLI) Run() {
 	command := os.Args[1]
 	cmd, exists := c.commands[command]
 	if !exists {
-		fmt.Println("Unknown command:", command)
+		fmt.Fprintln(os.Stderr, "Unknown command:", command)
 		c.ShowHelp()
 		os.Exit(1)
 	}
 
-	if err := cmd.Execute(os.Args[2:]); err != nil {
-		fmt.Println(err.Error())
+	args := os.Args[2:]
+	if err := setupLogging(args); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+
+	// SIGINT and SIGTERM cancel the context, which rolls back the migration
+	// in progress instead of leaving it half applied
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := cmd.Execute(ctx, args)
+	stop()
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func setupLogging(args []string) error {
+	level := slog.LevelInfo
+	switch {
+	case utils.HasFlag(args, "quiet"):
+		level = slog.LevelWarn
+	case utils.HasFlag(args, "verbose"):
+		level = slog.LevelDebug
+	}
+
+	// keep stdout for the JSON document
+	out := os.Stdout
+	if format, _ := utils.Flag(args, "output"); format == "json" {
+		out = os.Stderr
+	}
+
+	format, _ := utils.Flag(args, "log-format")
+	return logging.Setup(format, level, out)
 }
 
 func (c *CLI) ShowHelp() {
@@ -62,5 +99,25 @@ func (c *CLI) ShowHelp() {
 	}
 
 	fmt.Println("\nOptions:")
-	fmt.Println("  --dsn      Database connection string (required)")
+	fmt.Println("  --dsn                 Database connection string (required, repeat it to target a fleet)")
+	fmt.Println("  --dsn-file            File with one connection string per line, to target a fleet")
+	fmt.Println("  --config              Config file (default: vagabond.json)")
+	fmt.Println("  --schemas             Comma separated postgres schemas to use (default: public)")
+	fmt.Println("  --migrations-schema   Postgres schema holding the vagabond_migrations table")
+	fmt.Println("  --tenants             Run pack, unpack or status in every postgres schema matching a pattern")
+	fmt.Println("  --tenant-query        Query returning the tenant schemas, instead of --tenants")
+	fmt.Println("  --parallel            Number of tenants or databases migrated at the same time (default 4)")
+	fmt.Println("  --continue-on-error   Keep migrating the remaining tenants or databases after a failure")
+	fmt.Println("  --allow-out-of-order  Apply pending migrations older than the latest applied one")
+	fmt.Println("  --statement-timeout   Abort a migration statement running longer than this, e.g. 5m")
+	fmt.Println("  --lock-timeout        Give up waiting for a lock after this, e.g. 10s (busy_timeout on sqlite)")
+	fmt.Println("  --retries             Retry transient connection and migration errors this many times (default 0)")
+	fmt.Println("  --retry-delay         Wait before the first retry, doubled after each one (default 1s)")
+	fmt.Println("  --hook-policy         What a failing hook does: abort (default) or warn")
+	fmt.Println("  --var                 Set a migration variable, e.g. --var=app_role=web (repeatable)")
+	fmt.Println("  --versioning          Version new migrations with a timestamp (default) or a sequential number")
+	fmt.Println("  --log-format          Log format: text or json (json logs go to stderr)")
+	fmt.Println("  --quiet               Only log warnings and errors")
+	fmt.Println("  --verbose             Also log debug messages")
+	fmt.Println("  --output              Output of pack, unpack, status, sketch and validate: text or json")
 }
@@ -1,13 +1,27 @@
 package cli
 
-import cmd "github.com/jxdones/vagabond/commands"
+import (
+	"context"
+
+	cmd "github.com/jxdones/vagabond/commands"
+)
 
 func RegisterCommands(cli *CLI) {
-	cli.RegisterCommand(Command{"create", "<name>", "create migrations files", cmd.Create})
-	cli.RegisterCommand(Command{"pack", "", "apply pending migrations", cmd.PackMigration})
+	cli.RegisterCommand(Command{"create", "<name>", "create migrations files (--auto to diff the database against --schema, --template to scaffold)", cmd.Create})
+	cli.RegisterCommand(Command{"renumber", "", "give outstanding timestamped migrations sequential versions (--dsn keeps applied ones, or --all; --dry-run)", cmd.RenumberMigrations})
+	cli.RegisterCommand(Command{"import", "<dir>", "convert another tool's migrations and history (--from=goose|migrate|dbmate|flyway)", cmd.ImportMigrations})
+	cli.RegisterCommand(Command{"export", "<dir>", "write the migrations in another tool's layout (--to=goose|migrate|dbmate|flyway)", cmd.ExportMigrations})
+	cli.RegisterCommand(Command{"squash", "", "replace the migrations before --before=<version> with one baseline migration", cmd.SquashMigrations})
+	cli.RegisterCommand(Command{"pack", "", "apply pending migrations (--lint to refuse migrations with lint errors)", cmd.PackMigration})
 	cli.RegisterCommand(Command{"unpack", "[n]", "rollback last n migrations (default 1)", cmd.UnpackMigrations})
-	cli.RegisterCommand(Command{"sketch", "[dir]", "dump the current database schema. (default dir: migrations)", cmd.SketchSchema})
-	cli.RegisterCommand(Command{"help", "", "print this help message", func(_ []string) error {
+	cli.RegisterCommand(Command{"status", "", "show applied and pending migrations", cmd.ShowStatus})
+	cli.RegisterCommand(Command{"validate", "", "check migration file names, pairs and contents", cmd.ValidateMigrations})
+	cli.RegisterCommand(Command{"test", "", "check every migration survives up, down and up again on a scratch database (--junit=file)", cmd.TestMigrations})
+	cli.RegisterCommand(Command{"lint", "", "check pending migrations for dangerous operations (--rule=name:level, --dialect without --dsn)", cmd.LintMigrations})
+	cli.RegisterCommand(Command{"sketch", "[dir]", "dump the current database schema. (default dir: migrations, --format=sql|json|yaml)", cmd.SketchSchema})
+	cli.RegisterCommand(Command{"diagram", "[file]", "draw an ER diagram (--format=mermaid|dot|dbml, --include/--exclude=pattern)", cmd.DrawDiagram})
+	cli.RegisterCommand(Command{"docs", "[dir]", "generate a data dictionary (default dir: docs, --format=markdown|html)", cmd.GenerateDocs})
+	cli.RegisterCommand(Command{"help", "", "print this help message", func(_ context.Context, _ []string) error {
 		cli.ShowHelp()
 		return nil
 	}})
@@ -1,30 +1,117 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
 	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/schema"
 )
 
 const migrationPath = "migrations"
 
-func Create(args []string) error {
-	if len(args) == 0 {
+func Create(ctx context.Context, args []string) error {
+	positional := utils.Positional(args)
+	if len(positional) == 0 {
 		return fmt.Errorf("migration name required")
 	}
-	name := args[0]
+	name := positional[0]
 
 	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
 		err := os.Mkdir(migrationPath, 0o755)
 		if err != nil {
-			fmt.Println("error creating migrations directory:", err)
+			slog.Error("Failed to create migrations directory", "error", err)
 		}
 	}
 
-	err := migrations.CreateMigration(name)
+	version, err := newVersion(args)
+	if err != nil {
+		return err
+	}
+
+	if utils.HasFlag(args, "auto") {
+		return createAutoMigration(ctx, version, name, args)
+	}
+	if template, ok := utils.Flag(args, "template"); ok {
+		return createFromTemplate(version, name, template, args)
+	}
+
+	err = migrations.CreateMigration(version, name)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// newVersion numbers a new migration with the scheme from --versioning or
+// the config file, timestamps by default.
+func newVersion(args []string) (string, error) {
+	scheme, ok := utils.Flag(args, "versioning")
+	if !ok {
+		cfg, err := utils.LoadConfig(args)
+		if err != nil {
+			return "", err
+		}
+		scheme = cfg.Versioning
+	}
+	return migrations.NextVersion(scheme)
+}
+
+func createAutoMigration(ctx context.Context, version, name string, args []string) error {
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
+	}
+
+	desiredPath, ok := utils.Flag(args, "schema")
+	if !ok {
+		desiredPath = filepath.Join(migrationPath, "schema.sql")
+	}
+
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	up, down, err := schema.DiffFile(ctx, driver, desiredPath, cfg.Type)
+	if err != nil {
+		return fmt.Errorf("error computing schema diff: %w", err)
+	}
+
+	return migrations.CreateMigrationWithContent(version, name, up, down)
+}
+
+// createFromTemplate renders a template for the dialect given by --dialect,
+// or by the configured database, defaulting to postgres.
+func createFromTemplate(version, name, template string, args []string) error {
+	dialect, ok := utils.Flag(args, "dialect")
+	if !ok {
+		dialect = "postgres"
+		if dsn, err := utils.DSN(args); err == nil && utils.DBType(dsn) != "unknown" {
+			dialect = utils.DBType(dsn)
+		}
+	}
+
+	data := migrations.TemplateData{
+		Name:    name,
+		Columns: utils.FlagList(args, "columns"),
+		Dialect: dialect,
+	}
+	data.Table, _ = utils.Flag(args, "table")
+	data.Index, _ = utils.Flag(args, "index")
+	if template == "add_index" && len(data.Columns) == 0 {
+		return fmt.Errorf("the add_index template needs --columns")
+	}
+
+	up, down, err := migrations.RenderTemplate(template, data)
+	if err != nil {
+		return err
+	}
+	return migrations.CreateMigrationWithContent(version, name, up, down)
+}
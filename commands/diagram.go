@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/schema"
+)
+
+func DrawDiagram(ctx context.Context, args []string) error {
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
+	}
+
+	format, ok := utils.Flag(args, "format")
+	if !ok {
+		format = "mermaid"
+	}
+
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	model, err := driver.InspectSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("error inspecting schema: %w", err)
+	}
+
+	model, err = schema.FilterTables(model, utils.FlagList(args, "include"), utils.FlagList(args, "exclude"))
+	if err != nil {
+		return err
+	}
+
+	diagram, err := schema.Diagram(model, format)
+	if err != nil {
+		return err
+	}
+
+	positional := utils.Positional(args)
+	if len(positional) == 0 {
+		fmt.Print(diagram)
+		return nil
+	}
+
+	if err := os.WriteFile(positional[0], []byte(diagram), 0o644); err != nil {
+		return fmt.Errorf("error writing diagram: %w", err)
+	}
+	slog.Info("Created diagram", "file", positional[0])
+	return nil
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/schema"
+)
+
+const defaultDocsPath = "docs"
+
+func GenerateDocs(ctx context.Context, args []string) error {
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
+	}
+
+	format, ok := utils.Flag(args, "format")
+	if !ok {
+		format = "markdown"
+	}
+
+	path := defaultDocsPath
+	if positional := utils.Positional(args); len(positional) > 0 {
+		path = positional[0]
+	}
+
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	model, err := driver.InspectSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("error inspecting schema: %w", err)
+	}
+
+	var tables []string
+	for _, table := range model.Tables {
+		tables = append(tables, table.FullName())
+	}
+	history, err := migrations.TableHistory(ctx, driver, tables)
+	if err != nil {
+		return err
+	}
+
+	pages, err := schema.Docs(model, history, format)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return fmt.Errorf("error creating docs directory: %w", err)
+	}
+	for _, page := range pages {
+		if err := os.WriteFile(filepath.Join(path, page.Name), []byte(page.Content), 0o644); err != nil {
+			return fmt.Errorf("error writing %s: %w", page.Name, err)
+		}
+	}
+
+	slog.Info("Generated data dictionary", "tables", len(model.Tables), "dir", path)
+	return nil
+}
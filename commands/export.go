@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/interop"
+)
+
+func ExportMigrations(ctx context.Context, args []string) error {
+	tool, ok := utils.Flag(args, "to")
+	if !ok || !slices.Contains(interop.Tools(), tool) {
+		return fmt.Errorf("--to must be one of %s", strings.Join(interop.Tools(), ", "))
+	}
+	positional := utils.Positional(args)
+	if len(positional) == 0 {
+		return fmt.Errorf("directory for the %s migrations required", tool)
+	}
+
+	exported, err := interop.Export(positional[0], tool)
+	if err != nil {
+		return err
+	}
+	for _, m := range exported {
+		slog.Debug("Exported migration", "from", m.ID, "to", m.Script)
+	}
+	slog.Info("Exported migrations", "count", len(exported), "to", positional[0])
+
+	history, ok := utils.Flag(args, "history")
+	if !ok {
+		return nil
+	}
+	return exportHistory(ctx, args, tool, history, exported)
+}
+
+// exportHistory writes the statements recording the migrations applied on
+// the DSN's database in the target tool's history table.
+func exportHistory(ctx context.Context, args []string, tool, file string, exported []interop.Exported) error {
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return fmt.Errorf("--history needs the database the migrations were applied to: %w", err)
+	}
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	applied, err := driver.GetAppliedMigrationsList(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	table, _ := utils.Flag(args, "table")
+	statements, err := interop.HistoryStatements(tool, cfg.Type, table, exported, applied)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(statements), 0o644); err != nil {
+		return fmt.Errorf("error writing file %s: %w", file, err)
+	}
+	slog.Info("Wrote history statements", "file", file, "applied", len(applied))
+	return nil
+}
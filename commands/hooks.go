@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/hooks"
+	"github.com/jxdones/vagabond/internal/migrations"
+)
+
+// hookOptions reads the shell hooks and the failure policy from the config
+// file, with --hook-policy taking precedence over the configured policy.
+func hookOptions(args []string) (migrations.Hooks, error) {
+	cfg, err := utils.LoadConfig(args)
+	if err != nil {
+		return migrations.Hooks{}, err
+	}
+
+	for event := range cfg.Hooks.Commands {
+		if !slices.Contains(hooks.Events(), hooks.Event(event)) {
+			return migrations.Hooks{}, fmt.Errorf("unknown hook event %q: use before-run, before-each, after-each, after-run or on-error", event)
+		}
+	}
+
+	policy := cfg.Hooks.Policy
+	if flag, ok := utils.Flag(args, "hook-policy"); ok {
+		policy = flag
+	}
+	switch policy {
+	case "":
+		policy = migrations.HookAbort
+	case migrations.HookAbort, migrations.HookWarn:
+	default:
+		return migrations.Hooks{}, fmt.Errorf("invalid hook policy %q: use abort or warn", policy)
+	}
+
+	return migrations.Hooks{Commands: cfg.Hooks.Commands, Policy: policy}, nil
+}
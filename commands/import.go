@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/interop"
+)
+
+func ImportMigrations(ctx context.Context, args []string) error {
+	tool, ok := utils.Flag(args, "from")
+	if !ok || !slices.Contains(interop.Tools(), tool) {
+		return fmt.Errorf("--from must be one of %s", strings.Join(interop.Tools(), ", "))
+	}
+	positional := utils.Positional(args)
+	if len(positional) == 0 {
+		return fmt.Errorf("directory with the %s migrations required", tool)
+	}
+
+	if err := os.MkdirAll(migrationPath, 0o755); err != nil {
+		return fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+
+	// the history table is read from the database the migrations were
+	// applied to, without one only the files are converted
+	var driver db.Driver
+	if _, err := utils.DSN(args); err == nil {
+		cfg, err := utils.Config(args)
+		if err != nil {
+			return err
+		}
+		if driver, err = db.New(ctx, cfg); err != nil {
+			return err
+		}
+		defer driver.Close()
+	} else {
+		slog.Warn("No database given, importing the migration files without their history")
+	}
+
+	table, _ := utils.Flag(args, "table")
+	imported, err := interop.Import(ctx, driver, tool, positional[0], table)
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	for _, m := range imported {
+		slog.Debug("Imported migration", "from", m.From, "to", m.ID, "applied", m.Applied)
+		if m.Applied {
+			applied++
+		}
+	}
+	slog.Info("Imported migrations", "count", len(imported), "applied", applied)
+	return nil
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/lint"
+	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/output"
+)
+
+func LintMigrations(ctx context.Context, args []string) error {
+	doc, err := newDocument(args, "lint")
+	if err != nil {
+		return err
+	}
+	return finish(doc, lintMigrations(ctx, args, doc))
+}
+
+func lintMigrations(ctx context.Context, args []string, doc *output.Document) error {
+	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+		return fmt.Errorf("missing migrations directory")
+	}
+
+	rules, err := lintRules(args)
+	if err != nil {
+		return err
+	}
+
+	// Without a database every migration is linted, since there is no way
+	// to tell which ones are still pending.
+	if _, err := utils.DSN(args); err != nil {
+		dialect, ok := utils.Flag(args, "dialect")
+		if !ok {
+			dialect = "postgres"
+		}
+		files, err := migrations.UpFiles()
+		if err != nil {
+			return err
+		}
+		return lintFiles(doc, "", files, dialect, rules)
+	}
+
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
+	}
+
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	return lintPending(ctx, driver, doc, displayDSN(cfg.DSN), cfg.Type, rules)
+}
+
+func lintPending(ctx context.Context, driver db.Driver, doc *output.Document, database, dialect string, rules []lint.Rule) error {
+	files, err := migrations.PendingFiles(ctx, driver)
+	if err != nil {
+		return err
+	}
+	return lintFiles(doc, database, files, dialect, rules)
+}
+
+// lintFiles puts the findings in doc when there is one, stdout is reserved
+// for it, and otherwise prints them on stderr.
+func lintFiles(doc *output.Document, database string, files []string, dialect string, rules []lint.Rule) error {
+	if len(files) == 0 {
+		slog.Info("No migrations to lint")
+		return nil
+	}
+
+	var findings []lint.Finding
+	for _, file := range files {
+		found, err := lint.LintFile(file, dialect, rules)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+	}
+
+	addFindings(doc, database, findings)
+	if doc == nil {
+		for _, finding := range findings {
+			fmt.Fprintln(os.Stderr, finding)
+		}
+	}
+
+	errors, warnings := lint.Count(findings)
+	slog.Info("Linted migrations", "count", len(files), "errors", errors, "warnings", warnings)
+	if errors > 0 {
+		return fmt.Errorf("lint found %d error(s)", errors)
+	}
+	return nil
+}
+
+// lintRules applies the levels from the config file and then the --rule
+// flags, so a single run can relax or tighten a rule.
+func lintRules(args []string) ([]lint.Rule, error) {
+	cfg, err := utils.LoadConfig(args)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]string{}
+	for name, level := range cfg.Lint.Rules {
+		overrides[name] = level
+	}
+	for _, rule := range utils.FlagList(args, "rule") {
+		name, level, ok := strings.Cut(rule, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid --rule %q: expected name:level", rule)
+		}
+		overrides[name] = level
+	}
+	return lint.Configure(overrides)
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/lint"
+	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/output"
+)
+
+// documentMu guards documents filled in by fleet tasks running in parallel.
+var documentMu sync.Mutex
+
+// newDocument returns nil unless --output=json was given, in which case the
+// command fills the document instead of printing its human readable output.
+func newDocument(args []string, command string) (*output.Document, error) {
+	format, ok := utils.Flag(args, "output")
+	if !ok || format == "text" {
+		return nil, nil
+	}
+	if format != "json" {
+		return nil, fmt.Errorf("invalid --output %q: use text or json", format)
+	}
+	return output.New(command), nil
+}
+
+// finish writes doc, if any, with the outcome of the command.
+func finish(doc *output.Document, err error) error {
+	if doc == nil {
+		return err
+	}
+	doc.Fail(err)
+	if writeErr := doc.Write(os.Stdout); writeErr != nil && err == nil {
+		return writeErr
+	}
+	return err
+}
+
+func addResults(doc *output.Document, database string, results []migrations.Result) {
+	if doc == nil {
+		return
+	}
+
+	documentMu.Lock()
+	defer documentMu.Unlock()
+	for _, result := range results {
+		m := output.Migration{
+			ID:         result.ID,
+			Database:   database,
+			Status:     result.Status,
+			DurationMS: output.Milliseconds(result.Duration),
+		}
+		if result.Err != nil {
+			m.Error = result.Err.Error()
+		}
+		doc.Migrations = append(doc.Migrations, m)
+	}
+}
+
+func addFindings(doc *output.Document, database string, findings []lint.Finding) {
+	if doc == nil {
+		return
+	}
+
+	documentMu.Lock()
+	defer documentMu.Unlock()
+	for _, f := range findings {
+		doc.Findings = append(doc.Findings, output.Finding{
+			File:     f.File,
+			Line:     f.Line,
+			Database: database,
+			Rule:     f.Rule,
+			Level:    string(f.Level),
+			Message:  f.Message,
+		})
+	}
+}
@@ -1,35 +1,95 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/jxdones/vagabond/commands/utils"
 	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/fleet"
+	"github.com/jxdones/vagabond/internal/lint"
 	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/output"
 )
 
-func PackMigration(args []string) error {
-	dsn, err := utils.DSN(args)
+func PackMigration(ctx context.Context, args []string) error {
+	doc, err := newDocument(args, "pack")
 	if err != nil {
 		return err
 	}
+	return finish(doc, packMigration(ctx, args, doc))
+}
 
-	dbType := utils.DBType(dsn)
-	if dbType == "unknown" {
-		return fmt.Errorf("could not determine database type from DSN")
-	}
-
+func packMigration(ctx context.Context, args []string, doc *output.Document) error {
 	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
 		return fmt.Errorf("missing migrations directory")
 	}
-	driver, err := db.New(db.Config{Type: dbType, DSN: dsn})
+
+	hooks, err := hookOptions(args)
+	if err != nil {
+		return err
+	}
+	vars, err := utils.Vars(args)
+	if err != nil {
+		return err
+	}
+	opts := migrations.ApplyOptions{AllowOutOfOrder: utils.HasFlag(args, "allow-out-of-order"), Hooks: hooks, Vars: vars}
+
+	var rules []lint.Rule
+	if utils.HasFlag(args, "lint") {
+		if rules, err = lintRules(args); err != nil {
+			return err
+		}
+	}
+
+	task := func(ctx context.Context, driver db.Driver, target fleet.Target, logger *slog.Logger) (int, error) {
+		cfg := target.Config
+		pending, err := migrations.PendingCount(ctx, driver)
+		if err != nil || pending == 0 {
+			return 0, err
+		}
+		if rules != nil {
+			if err := lintPending(ctx, driver, doc, target.Name, cfg.Type, rules); err != nil {
+				return 0, err
+			}
+		}
+		apply := opts
+		apply.Logger = logger
+		apply.Retry = cfg.Retry
+		apply.Hooks.Database = target.Name
+		results, err := migrations.ApplyMigrations(ctx, driver, cfg.Type, apply)
+		addResults(doc, target.Name, results)
+		return pending, err
+	}
+	if multi, err := runTargets(ctx, args, task, false, doc); multi {
+		return err
+	}
+
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
+	}
+
+	driver, err := db.New(ctx, cfg)
 	if err != nil {
 		return err
 	}
 	defer driver.Close()
 
-	if err := migrations.ApplyMigrations(driver, dbType); err != nil {
+	if rules != nil {
+		if err := lintPending(ctx, driver, doc, displayDSN(cfg.DSN), cfg.Type, rules); err != nil {
+			return fmt.Errorf("refusing to apply migrations: %w", err)
+		}
+	}
+
+	opts.Logger = databaseLogger(cfg.DSN)
+	opts.Retry = cfg.Retry
+	opts.Hooks.Database = displayDSN(cfg.DSN)
+	results, err := migrations.ApplyMigrations(ctx, driver, cfg.Type, opts)
+	addResults(doc, displayDSN(cfg.DSN), results)
+	if err != nil {
 		return fmt.Errorf("error applying migrations: %w", err)
 	}
 
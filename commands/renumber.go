@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/migrations"
+)
+
+func RenumberMigrations(ctx context.Context, args []string) error {
+	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+		return fmt.Errorf("missing migrations directory")
+	}
+
+	// migrations already applied to the configured database are recorded
+	// there under their timestamp, so they keep it. Without a database every
+	// timestamped migration would be renamed, which has to be asked for.
+	keep := map[string]bool{}
+	if _, err := utils.DSN(args); err != nil {
+		if !utils.HasFlag(args, "all") {
+			return fmt.Errorf("renumber needs --dsn of a database where the history is applied, whose migrations keep their timestamp, or --all to renumber every timestamped migration")
+		}
+	} else {
+		cfg, err := utils.Config(args)
+		if err != nil {
+			return err
+		}
+		driver, err := db.New(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer driver.Close()
+
+		if keep, err = driver.GetAppliedMigrations(ctx); err != nil {
+			return fmt.Errorf("could not get applied migrations: %w", err)
+		}
+	}
+
+	dryRun := utils.HasFlag(args, "dry-run")
+	renames, err := migrations.Renumber(keep, dryRun)
+	for _, rename := range renames {
+		if dryRun {
+			fmt.Printf("%s -> %s\n", rename.From, rename.To)
+		} else {
+			slog.Info("Renumbered migration", "from", rename.From, "to", rename.To)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(renames) == 0 {
+		slog.Info("No timestamped migrations to renumber")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRenumberMigrations(t *testing.T) {
+	applied := map[string]string{
+		"20240117093012_add_users_up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"20240117093012_add_users_down.sql": "DROP TABLE users;",
+	}
+	branch := map[string]string{
+		"20240301120000_add_posts_up.sql":   "CREATE TABLE posts (id INTEGER PRIMARY KEY);",
+		"20240301120000_add_posts_down.sql": "DROP TABLE posts;",
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "no database",
+			wantErr: "renumber needs --dsn of a database where the history is applied, whose migrations keep their timestamp, or --all to renumber every timestamped migration",
+			want:    "20240117093012_add_users_down.sql 20240117093012_add_users_up.sql 20240301120000_add_posts_down.sql 20240301120000_add_posts_up.sql",
+		},
+		{
+			name: "applied migrations keep their timestamp",
+			args: []string{"--dsn=app.db"},
+			want: "0001_add_posts_down.sql 0001_add_posts_up.sql 20240117093012_add_users_down.sql 20240117093012_add_users_up.sql",
+		},
+		{
+			name: "all",
+			args: []string{"--all"},
+			want: "0001_add_users_down.sql 0001_add_users_up.sql 0002_add_posts_down.sql 0002_add_posts_up.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			t.Chdir(t.TempDir())
+			writeFiles(t, migrationPath, applied)
+			if err := PackMigration(ctx, []string{"--dsn=app.db"}); err != nil {
+				t.Fatal(err)
+			}
+			writeFiles(t, migrationPath, branch)
+
+			err := RenumberMigrations(ctx, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("RenumberMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("RenumberMigrations() error = %v", err)
+			}
+
+			entries, err := os.ReadDir(migrationPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			if got := strings.Join(files, " "); got != tt.want {
+				t.Errorf("migrations = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
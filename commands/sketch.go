@@ -1,51 +1,66 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/jxdones/vagabond/commands/utils"
 	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/output"
 	"github.com/jxdones/vagabond/internal/schema"
 )
 
-func SketchSchema(args []string) error {
-	dsn, err := utils.DSN(args)
+func SketchSchema(ctx context.Context, args []string) error {
+	doc, err := newDocument(args, "sketch")
 	if err != nil {
 		return err
 	}
+	return finish(doc, sketchSchema(ctx, args, doc))
+}
 
-	dbType := utils.DBType(dsn)
-	if dbType == "unknown" {
-		return fmt.Errorf("could not determine database type from DSN")
+func sketchSchema(ctx context.Context, args []string, doc *output.Document) error {
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
 	}
 
 	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
 		return fmt.Errorf("missing migrations directory")
 	}
 
+	format, ok := utils.Flag(args, "format")
+	if !ok {
+		format = "sql"
+	}
+	if format != "sql" && format != "json" && format != "yaml" {
+		return fmt.Errorf("invalid format %q: use sql, json or yaml", format)
+	}
+
 	var path string
-	if len(args) > 0 && !strings.Contains(args[0], dsn) {
-		path = args[0]
+	if positional := utils.Positional(args); len(positional) > 0 {
+		path = positional[0]
 	}
 
 	if path == "" {
 		path = migrationPath
 	}
 
-	schemaPath := filepath.Join(path, "schema.sql")
+	schemaPath := filepath.Join(path, schema.FileName(format))
 
-	driver, err := db.New(db.Config{Type: dbType, DSN: dsn})
+	driver, err := db.New(ctx, cfg)
 	if err != nil {
 		return err
 	}
 	defer driver.Close()
 
-	if err := schema.DumpSchema(driver, schemaPath); err != nil {
+	if err := schema.ExportSchema(ctx, driver, cfg.Type, format, schemaPath); err != nil {
 		return fmt.Errorf("error dumping schema: %w", err)
 	}
 
+	if doc != nil {
+		doc.Files = append(doc.Files, schemaPath)
+	}
 	return nil
 }
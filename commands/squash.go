@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/migrations"
+)
+
+func SquashMigrations(ctx context.Context, args []string) error {
+	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+		return fmt.Errorf("missing migrations directory")
+	}
+	before, ok := utils.Flag(args, "before")
+	if !ok {
+		return fmt.Errorf("--before=<version> is required")
+	}
+
+	// the baseline is the scratch database's dump, in its dialect
+	dsn, err := scratchDSN(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := utils.ConfigFor(args, dsn)
+	if err != nil {
+		return err
+	}
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	vars, err := utils.Vars(args)
+	if err != nil {
+		return err
+	}
+
+	baseline, err := migrations.Squash(ctx, driver, before, vars)
+	if err != nil {
+		return err
+	}
+	slog.Info("Squashed migrations", "count", len(baseline.Squashed), "baseline", baseline.ID)
+	return nil
+}
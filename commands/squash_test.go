@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+var squashMigrations = map[string]string{
+	"0001_users_up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT NOT NULL CHECK (email LIKE '%@%'));",
+	"0001_users_down.sql": "DROP TABLE users;",
+	"0002_posts_up.sql": `CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id), title TEXT);
+CREATE INDEX posts_user_id ON posts (user_id);
+CREATE VIEW titles AS SELECT title FROM posts;
+CREATE VIEW all_titles AS SELECT title FROM titles;`,
+	"0002_posts_down.sql": "DROP VIEW all_titles; DROP VIEW titles; DROP TABLE posts;",
+	"0003_audit_up.sql": `ALTER TABLE users ADD COLUMN name TEXT;
+CREATE TABLE audit (at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, what TEXT);
+CREATE TRIGGER users_audit AFTER INSERT ON users BEGIN INSERT INTO audit (what) VALUES ('user; ' || NEW.email); END;`,
+	"0003_audit_down.sql": "DROP TRIGGER users_audit; DROP TABLE audit;",
+	"0004_later_up.sql":   "CREATE TABLE later (id INTEGER PRIMARY KEY);",
+	"0004_later_down.sql": "DROP TABLE later;",
+}
+
+// TestSquashMigrations checks that a fresh database built from the baseline
+// has the schema of one that applied the squashed migrations, and that the
+// latter records the baseline instead of running it.
+func TestSquashMigrations(t *testing.T) {
+	ctx := context.Background()
+	t.Chdir(t.TempDir())
+	writeFiles(t, migrationPath, squashMigrations)
+
+	if err := PackMigration(ctx, []string{"--dsn=existing.db"}); err != nil {
+		t.Fatalf("pack before squashing: %v", err)
+	}
+	if err := SquashMigrations(ctx, []string{"--before=0004", "--dsn=scratch.db"}); err != nil {
+		t.Fatalf("SquashMigrations() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(migrationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	if got, want := strings.Join(files, " "), "0003_baseline_down.sql 0003_baseline_up.sql 0004_later_down.sql 0004_later_up.sql"; got != want {
+		t.Fatalf("migrations after squashing = %s, want %s", got, want)
+	}
+
+	for _, dsn := range []string{"existing.db", "fresh.db"} {
+		if err := PackMigration(ctx, []string{"--dsn=" + dsn}); err != nil {
+			t.Fatalf("pack %s after squashing: %v", dsn, err)
+		}
+	}
+
+	existing, fresh := dumpSchema(t, "existing.db"), dumpSchema(t, "fresh.db")
+	if existing != fresh {
+		t.Errorf("schema built from the baseline differs:\n%s\nwant\n%s", fresh, existing)
+	}
+	if !strings.Contains(fresh, "AUTOINCREMENT") || !strings.Contains(fresh, "CREATE TRIGGER users_audit") {
+		t.Errorf("baseline lost parts of the schema:\n%s", fresh)
+	}
+}
+
+func TestSquashMigrationsScratch(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "no scratch database",
+			config:  `{"dsn": "app.db"}`,
+			args:    []string{"--before=0004"},
+			wantErr: "--dsn with an empty scratch database of the project's kind is required",
+		},
+		{
+			name:    "sqlite scratch for a postgres project",
+			config:  `{"dsn": "postgres://localhost/app"}`,
+			args:    []string{"--before=0004", "--dsn=scratch.db"},
+			wantErr: "the scratch database is sqlite but the project uses postgres, pass a postgres --dsn",
+		},
+		{
+			name:    "no version",
+			args:    []string{"--dsn=scratch.db"},
+			wantErr: "--before=<version> is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			writeFiles(t, migrationPath, squashMigrations)
+			if tt.config != "" {
+				if err := os.WriteFile("vagabond.json", []byte(tt.config), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := SquashMigrations(context.Background(), tt.args)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("SquashMigrations() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(migrationPath, "0001_users_up.sql")); err != nil {
+				t.Errorf("migrations changed: %v", err)
+			}
+		})
+	}
+}
+
+// dumpSchema dumps the schema of a database, without the applied migrations.
+func dumpSchema(t *testing.T, dsn string) string {
+	t.Helper()
+	ctx := context.Background()
+	driver, err := db.New(ctx, db.Config{Type: "sqlite", DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	dump, err := driver.DumpSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, _, _ := strings.Cut(dump, "INSERT INTO vagabond_migrations")
+	return schema
+}
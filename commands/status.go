@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/fleet"
+	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/output"
+)
+
+func ShowStatus(ctx context.Context, args []string) error {
+	doc, err := newDocument(args, "status")
+	if err != nil {
+		return err
+	}
+	return finish(doc, showStatus(ctx, args, doc))
+}
+
+func showStatus(ctx context.Context, args []string, doc *output.Document) error {
+	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+		return fmt.Errorf("missing migrations directory")
+	}
+
+	task := func(ctx context.Context, driver db.Driver, _ fleet.Target, _ *slog.Logger) (int, error) {
+		return migrations.PendingCount(ctx, driver)
+	}
+	if multi, err := runTargets(ctx, args, task, true, doc); multi {
+		return err
+	}
+
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
+	}
+
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	statuses, err := migrations.Status(ctx, driver)
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		slog.Info("No migrations found")
+		return nil
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		state := "applied"
+		if !status.Applied {
+			state = "pending"
+			if status.OutOfOrder {
+				state = "late"
+			}
+			pending++
+		}
+
+		if doc != nil {
+			doc.Migrations = append(doc.Migrations, output.Migration{
+				ID:           status.ID,
+				Database:     displayDSN(cfg.DSN),
+				Status:       state,
+				Irreversible: status.Irreversible,
+			})
+			continue
+		}
+
+		note := ""
+		if status.Irreversible {
+			note = " (irreversible)"
+		}
+		fmt.Printf("%-8s %s%s\n", state, status.ID, note)
+	}
+	if doc == nil {
+		fmt.Printf("%d applied, %d pending.\n", len(statuses)-pending, pending)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/fleet"
+	"github.com/jxdones/vagabond/internal/output"
+	"github.com/jxdones/vagabond/internal/tenant"
+)
+
+const defaultParallelism = 4
+
+// runTargets runs task on every tenant schema or fleet database selected by
+// args. It returns false when args select a single database. With a doc the
+// results are added to it instead of printed as a table.
+func runTargets(ctx context.Context, args []string, task fleet.Task, dryRun bool, doc *output.Document) (bool, error) {
+	opts := fleet.Options{
+		Parallel:        defaultParallelism,
+		ContinueOnError: utils.HasFlag(args, "continue-on-error"),
+		DryRun:          dryRun,
+	}
+	if value, ok := utils.Flag(args, "parallel"); ok {
+		parallel, err := strconv.Atoi(value)
+		if err != nil || parallel < 1 {
+			return true, fmt.Errorf("invalid --parallel: provide a positive number")
+		}
+		opts.Parallel = parallel
+	}
+
+	pattern, hasPattern := utils.Flag(args, "tenants")
+	query, hasQuery := utils.Flag(args, "tenant-query")
+	if hasPattern || hasQuery {
+		cfg, err := utils.Config(args)
+		if err != nil {
+			return true, err
+		}
+		if cfg.Type != "postgres" {
+			return true, fmt.Errorf("multi-tenant mode requires postgres")
+		}
+
+		targets, err := tenant.Targets(ctx, cfg, tenant.Options{Pattern: pattern, Query: query})
+		if err != nil {
+			return true, err
+		}
+		return true, report(doc, "TENANT", fleet.Run(ctx, targets, opts, task))
+	}
+
+	dsns, err := utils.DSNs(args)
+	if err != nil {
+		return true, err
+	}
+	_, hasFile := utils.Flag(args, "dsn-file")
+	if len(dsns) < 2 && !hasFile {
+		return false, nil
+	}
+
+	targets := make([]fleet.Target, len(dsns))
+	for i, dsn := range dsns {
+		cfg, err := utils.ConfigFor(args, dsn)
+		if err != nil {
+			return true, fmt.Errorf("%s: %w", displayDSN(dsn), err)
+		}
+		targets[i] = fleet.Target{Name: displayDSN(dsn), Config: cfg}
+	}
+	return true, report(doc, "DATABASE", fleet.Run(ctx, targets, opts, task))
+}
+
+func report(doc *output.Document, label string, results []fleet.Result) error {
+	if doc == nil {
+		return fleet.Report(label, results)
+	}
+
+	for _, r := range results {
+		target := output.Target{Name: r.Target, Status: r.Status, Migrations: r.Changes}
+		if r.Err != nil {
+			target.Error = r.Err.Error()
+		}
+		doc.Targets = append(doc.Targets, target)
+	}
+	return fleet.Check(results)
+}
+
+func databaseLogger(dsn string) *slog.Logger {
+	return slog.Default().With("database", displayDSN(dsn))
+}
+
+func displayDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return dsn
+}
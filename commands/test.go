@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jxdones/vagabond/commands/utils"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/migrations"
+)
+
+func TestMigrations(ctx context.Context, args []string) error {
+	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+		return fmt.Errorf("missing migrations directory")
+	}
+
+	dsn, err := scratchDSN(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := utils.ConfigFor(args, dsn)
+	if err != nil {
+		return err
+	}
+
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	vars, err := utils.Vars(args)
+	if err != nil {
+		return err
+	}
+
+	results, err := migrations.TestReversibility(ctx, driver, vars)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		slog.Info("No migrations found")
+		return nil
+	}
+
+	failed, skipped := 0, 0
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			skipped++
+			fmt.Printf("skip  %s\n", result.ID)
+		case result.Err != nil:
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", result.ID, result.Err)
+			for _, line := range result.Diff {
+				fmt.Printf("        %s\n", line)
+			}
+		default:
+			fmt.Printf("ok    %s (%s)\n", result.ID, result.Duration.Round(time.Microsecond))
+		}
+	}
+	fmt.Printf("%d reversible, %d not reversible, %d skipped.\n", len(results)-failed-skipped, failed, skipped)
+
+	if path, ok := utils.Flag(args, "junit"); ok {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create junit report: %w", err)
+		}
+		defer file.Close()
+		if err := migrations.WriteJUnit(file, results); err != nil {
+			return fmt.Errorf("failed to write junit report: %w", err)
+		}
+		slog.Info("Created JUnit report", "file", path)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d migration(s) are not reversible", failed)
+	}
+	return nil
+}
+
+// scratchDSN returns the --dsn of the empty database test and squash run
+// the migrations on. Only an explicit --dsn is used: the configured dsn
+// usually points at a real database, it only tells the kind of database,
+// as does --dialect, that the scratch one has to be.
+func scratchDSN(args []string) (string, error) {
+	dsn, ok := utils.Flag(args, "dsn")
+	if !ok {
+		return "", fmt.Errorf("--dsn with an empty scratch database of the project's kind is required")
+	}
+
+	dialect, ok := utils.Flag(args, "dialect")
+	if !ok {
+		cfg, err := utils.LoadConfig(args)
+		if err != nil {
+			return "", err
+		}
+		configured := cfg.DSN
+		if configured == "" && len(cfg.DSNs) > 0 {
+			configured = cfg.DSNs[0]
+		}
+		dialect = utils.DBType(configured)
+	}
+	if scratch := utils.DBType(dsn); dialect != "unknown" && scratch != dialect {
+		return "", fmt.Errorf("the scratch database is %s but the project uses %s, pass a %s --dsn", scratch, dialect, dialect)
+	}
+	return dsn, nil
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScratchDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string // vagabond.json, if any
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "no dsn",
+			args:    nil,
+			wantErr: "--dsn with an empty scratch database of the project's kind is required",
+		},
+		{
+			name:    "configured dsn is not a scratch database",
+			config:  `{"dsn": "app.db"}`,
+			wantErr: "--dsn with an empty scratch database of the project's kind is required",
+		},
+		{
+			name: "no configured database",
+			args: []string{"--dsn=scratch.db"},
+			want: "scratch.db",
+		},
+		{
+			name:   "same kind as the configured database",
+			config: `{"dsn": "postgres://localhost/app"}`,
+			args:   []string{"--dsn=postgres://localhost/scratch"},
+			want:   "postgres://localhost/scratch",
+		},
+		{
+			name:    "sqlite scratch for a postgres project",
+			config:  `{"dsn": "postgres://localhost/app"}`,
+			args:    []string{"--dsn=scratch.db"},
+			wantErr: "the scratch database is sqlite but the project uses postgres, pass a postgres --dsn",
+		},
+		{
+			name:    "sqlite scratch for a postgres fleet",
+			config:  `{"dsns": ["postgres://a/app", "postgres://b/app"]}`,
+			args:    []string{"--dsn=scratch.db"},
+			wantErr: "the scratch database is sqlite but the project uses postgres, pass a postgres --dsn",
+		},
+		{
+			name:    "dialect flag",
+			args:    []string{"--dsn=scratch.db", "--dialect=postgres"},
+			wantErr: "the scratch database is sqlite but the project uses postgres, pass a postgres --dsn",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if tt.config != "" {
+				if err := os.WriteFile("vagabond.json", []byte(tt.config), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := scratchDSN(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("scratchDSN() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scratchDSN() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("scratchDSN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		junit   []string // in the JUnit report
+		wantErr string
+	}{
+		{
+			name: "reversible",
+			files: map[string]string{
+				"0001_users_up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"0001_users_down.sql": "DROP TABLE users;",
+			},
+			junit: []string{`tests="1" failures="0"`},
+		},
+		{
+			name: "down leaves a table behind",
+			files: map[string]string{
+				"0001_users_up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+				"0001_users_down.sql": "SELECT 1;",
+			},
+			junit:   []string{`tests="1" failures="1"`, "0001_users_up"},
+			wantErr: "1 migration(s) are not reversible",
+		},
+		{
+			name: "irreversible migration is skipped",
+			files: map[string]string{
+				"0001_users_up.sql": "-- vagabond:irreversible\nCREATE TABLE users (id INTEGER PRIMARY KEY);",
+			},
+			junit: []string{`skipped="1"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			writeFiles(t, migrationPath, tt.files)
+
+			err := TestMigrations(context.Background(), []string{"--dsn=scratch.db", "--junit=report.xml"})
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("TestMigrations() error = %v, want %q", err, tt.wantErr)
+			}
+			report, err := os.ReadFile("report.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.junit {
+				if !strings.Contains(string(report), want) {
+					t.Errorf("JUnit report does not contain %q:\n%s", want, report)
+				}
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -1,36 +1,37 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
-	"strings"
 
 	"github.com/jxdones/vagabond/commands/utils"
 	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/fleet"
 	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/output"
 )
 
 const defaultRollbackCount = 1
 
-func UnpackMigrations(args []string) error {
-	dsn, err := utils.DSN(args)
+func UnpackMigrations(ctx context.Context, args []string) error {
+	doc, err := newDocument(args, "unpack")
 	if err != nil {
 		return err
 	}
+	return finish(doc, unpackMigrations(ctx, args, doc))
+}
 
-	dbType := utils.DBType(dsn)
-	if dbType == "unknown" {
-		return fmt.Errorf("could not determine database type from DSN")
-	}
-
+func unpackMigrations(ctx context.Context, args []string, doc *output.Document) error {
 	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
 		return fmt.Errorf("missing migrations directory")
 	}
 
 	var n int
-	if len(args) > 0 && !strings.Contains(args[0], dsn) {
-		parsed, err := strconv.Atoi(args[0])
+	if positional := utils.Positional(args); len(positional) > 0 {
+		parsed, err := strconv.Atoi(positional[0])
 		if err != nil {
 			return fmt.Errorf("invalid option: provide a number")
 		}
@@ -41,11 +42,41 @@ func UnpackMigrations(args []string) error {
 		n = defaultRollbackCount
 	}
 
-	driver, err := db.New(db.Config{Type: dbType, DSN: dsn})
+	hooks, err := hookOptions(args)
+	if err != nil {
+		return err
+	}
+
+	vars, err := utils.Vars(args)
+	if err != nil {
+		return err
+	}
+
+	task := func(ctx context.Context, driver db.Driver, target fleet.Target, logger *slog.Logger) (int, error) {
+		opts := migrations.RollbackOptions{Logger: logger, Retry: target.Config.Retry, Hooks: hooks, Vars: vars}
+		opts.Hooks.Database = target.Name
+		results, err := migrations.RollbackMigrations(ctx, driver, n, opts)
+		addResults(doc, target.Name, results)
+		return len(results), err
+	}
+	if multi, err := runTargets(ctx, args, task, false, doc); multi {
+		return err
+	}
+
+	cfg, err := utils.Config(args)
+	if err != nil {
+		return err
+	}
+
+	driver, err := db.New(ctx, cfg)
 	if err != nil {
 		return err
 	}
 	defer driver.Close()
 
-	return migrations.RollbackMigrations(driver, n)
+	opts := migrations.RollbackOptions{Logger: databaseLogger(cfg.DSN), Retry: cfg.Retry, Hooks: hooks, Vars: vars}
+	opts.Hooks.Database = displayDSN(cfg.DSN)
+	results, err := migrations.RollbackMigrations(ctx, driver, n, opts)
+	addResults(doc, displayDSN(cfg.DSN), results)
+	return err
 }
@@ -2,7 +2,14 @@ package utils
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jxdones/vagabond/internal/config"
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/retry"
 )
 
 func DSN(args []string) (string, error) {
@@ -11,9 +18,55 @@ func DSN(args []string) (string, error) {
 			return strings.TrimPrefix(arg, "--dsn="), nil
 		}
 	}
+
+	cfg, err := LoadConfig(args)
+	if err != nil {
+		return "", err
+	}
+	if cfg.DSN != "" {
+		return cfg.DSN, nil
+	}
 	return "", fmt.Errorf("--dsn argument is required")
 }
 
+// DSNs collects the databases of a fleet from repeated --dsn flags and a
+// --dsn-file, falling back to the dsns listed in the config file.
+func DSNs(args []string) ([]string, error) {
+	var dsns []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--dsn=") {
+			dsns = append(dsns, strings.TrimPrefix(arg, "--dsn="))
+		}
+	}
+
+	if path, ok := Flag(args, "dsn-file"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dsn file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				dsns = append(dsns, line)
+			}
+		}
+	}
+
+	if len(dsns) == 0 {
+		cfg, err := LoadConfig(args)
+		if err != nil {
+			return nil, err
+		}
+		dsns = cfg.DSNs
+	}
+	return dsns, nil
+}
+
+func LoadConfig(args []string) (*config.Config, error) {
+	path, _ := Flag(args, "config")
+	return config.Load(path)
+}
+
 func DBType(dsn string) string {
 	dsn = strings.ToLower(dsn)
 
@@ -26,3 +79,153 @@ func DBType(dsn string) string {
 		return "unknown"
 	}
 }
+
+func Flag(args []string, name string) (string, bool) {
+	prefix := "--" + name + "="
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return strings.TrimPrefix(arg, prefix), true
+		}
+	}
+	return "", false
+}
+
+func HasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--"+name || arg == "--"+name+"=true" {
+			return true
+		}
+	}
+	return false
+}
+
+func Positional(args []string) []string {
+	var positional []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+		}
+	}
+	return positional
+}
+
+func FlagList(args []string, name string) []string {
+	prefix := "--" + name + "="
+	var values []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, prefix) {
+			continue
+		}
+		for _, value := range strings.Split(strings.TrimPrefix(arg, prefix), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func Config(args []string) (db.Config, error) {
+	dsn, err := DSN(args)
+	if err != nil {
+		return db.Config{}, err
+	}
+	return ConfigFor(args, dsn)
+}
+
+func ConfigFor(args []string, dsn string) (db.Config, error) {
+	dbType := DBType(dsn)
+	if dbType == "unknown" {
+		return db.Config{}, fmt.Errorf("could not determine database type from DSN")
+	}
+
+	statementTimeout, err := Duration(args, "statement-timeout")
+	if err != nil {
+		return db.Config{}, err
+	}
+	lockTimeout, err := Duration(args, "lock-timeout")
+	if err != nil {
+		return db.Config{}, err
+	}
+
+	retryPolicy, err := RetryPolicy(args)
+	if err != nil {
+		return db.Config{}, err
+	}
+
+	migrationsSchema, _ := Flag(args, "migrations-schema")
+	return db.Config{
+		Type:             dbType,
+		DSN:              dsn,
+		Schemas:          FlagList(args, "schemas"),
+		MigrationsSchema: migrationsSchema,
+		StatementTimeout: statementTimeout,
+		LockTimeout:      lockTimeout,
+		Retry:            retryPolicy,
+	}, nil
+}
+
+func Duration(args []string, name string) (time.Duration, error) {
+	value, ok := Flag(args, name)
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid --%s: provide a duration such as 30s or 500ms", name)
+	}
+	return d, nil
+}
+
+// RetryPolicy reads --retries, how many times a transient failure is
+// retried, and --retry-delay, the wait before the first retry.
+func RetryPolicy(args []string) (retry.Policy, error) {
+	policy := retry.Policy{Delay: time.Second}
+	if value, ok := Flag(args, "retries"); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return retry.Policy{}, fmt.Errorf("invalid --retries: provide a number")
+		}
+		policy.Attempts = n
+	}
+	if _, ok := Flag(args, "retry-delay"); ok {
+		delay, err := Duration(args, "retry-delay")
+		if err != nil {
+			return retry.Policy{}, err
+		}
+		policy.Delay = delay
+	}
+	return policy, nil
+}
+
+// Vars resolves migration variables from repeated --var=name=value flags,
+// then environment variables, then the vars of the config file.
+func Vars(args []string) (func(name string) (string, bool), error) {
+	cfg, err := LoadConfig(args)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := map[string]string{}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--var=") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(arg, "--var="), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --var %q: expected name=value", strings.TrimPrefix(arg, "--var="))
+		}
+		flags[name] = value
+	}
+
+	return func(name string) (string, bool) {
+		if value, ok := flags[name]; ok {
+			return value, true
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := cfg.Vars[name]
+		return value, ok
+	}, nil
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/output"
+)
+
+func ValidateMigrations(_ context.Context, args []string) error {
+	doc, err := newDocument(args, "validate")
+	if err != nil {
+		return err
+	}
+	return finish(doc, validateMigrations(doc))
+}
+
+func validateMigrations(doc *output.Document) error {
+	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+		return fmt.Errorf("missing migrations directory")
+	}
+
+	problems, err := migrations.Validate()
+	if err != nil {
+		return err
+	}
+
+	irreversible, err := migrations.Irreversible()
+	if err != nil {
+		return err
+	}
+
+	if doc != nil {
+		doc.Irreversible = irreversible
+		for _, problem := range problems {
+			doc.Problems = append(doc.Problems, output.Problem{File: filepath.Join(migrationPath, problem.File), Message: problem.Message})
+		}
+	} else {
+		for _, id := range irreversible {
+			fmt.Printf("%s: irreversible\n", filepath.Join(migrationPath, id+".sql"))
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", filepath.Join(migrationPath, problem.File), problem.Message)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s) in the migrations directory", len(problems))
+	}
+	slog.Info("Migrations directory is valid")
+	return nil
+}
@@ -1,10 +1,13 @@
 package commands
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 const VERSION = "0.0.1"
 
-func ShowVersion(_ []string) error {
+func ShowVersion(_ context.Context, _ []string) error {
 	fmt.Println("vagabond version", VERSION)
 	return nil
 }
@@ -5,3 +5,5 @@ go 1.24.1
 require github.com/mattn/go-sqlite3 v1.14.24
 
 require github.com/lib/pq v1.10.9
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,62 @@
+// Package hooks runs Go code around migrations for programs that embed the
+// vagabond CLI. Register callbacks before calling Run on the CLI:
+//
+//	hooks.Register(hooks.AfterRun, func(ctx context.Context, info hooks.Info) error {
+//		return notify(ctx, info.Database)
+//	})
+//	vagabond := cli.NewCli()
+//	cli.RegisterCommands(vagabond)
+//	vagabond.Run()
+package hooks
+
+import (
+	"context"
+	"sync"
+)
+
+type Event string
+
+const (
+	BeforeRun  Event = "before-run"
+	BeforeEach Event = "before-each"
+	AfterEach  Event = "after-each"
+	AfterRun   Event = "after-run"
+	OnError    Event = "on-error"
+)
+
+// Events lists the hook events in the order they fire during a run.
+func Events() []Event {
+	return []Event{BeforeRun, BeforeEach, AfterEach, AfterRun, OnError}
+}
+
+// Info describes the run or migration a hook fires for.
+type Info struct {
+	Event     Event
+	Database  string
+	Direction string // up for pack, down for unpack
+	Migration string // empty for before-run and after-run
+	Status    string // applied, rolled_back or failed once the outcome is known
+	Err       error  // the migration error, for on-error
+}
+
+type Func func(ctx context.Context, info Info) error
+
+var (
+	mu        sync.Mutex
+	callbacks = map[Event][]Func{}
+)
+
+// Register adds fn to the callbacks run for event, after the SQL and shell
+// hooks of the same event.
+func Register(event Event, fn Func) {
+	mu.Lock()
+	defer mu.Unlock()
+	callbacks[event] = append(callbacks[event], fn)
+}
+
+// Registered returns the callbacks for event in registration order.
+func Registered(event Event) []Func {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Func(nil), callbacks[event]...)
+}
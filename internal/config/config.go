@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const DefaultPath = "vagabond.json"
+
+type Config struct {
+	DSN   string   `json:"dsn"`
+	DSNs  []string `json:"dsns"`
+	Lint  Lint     `json:"lint"`
+	Hooks Hooks    `json:"hooks"`
+	// Vars are the values of ${name} placeholders in migrations.
+	Vars map[string]string `json:"vars"`
+	// Versioning numbers new migrations: timestamp (default) or sequential.
+	Versioning string `json:"versioning"`
+}
+
+type Lint struct {
+	Rules map[string]string `json:"rules"`
+}
+
+// Hooks holds the shell commands to run for each hook event and what to do
+// when one of them fails.
+type Hooks struct {
+	Policy   string              `json:"policy"`
+	Commands map[string][]string `json:"commands"`
+}
+
+// Load reads the config file at path. The default file is optional, so a
+// missing vagabond.json yields an empty config rather than an error.
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -1,27 +1,43 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
+
+	"github.com/jxdones/vagabond/internal/retry"
 )
 
 type Config struct {
-	Type string
-	DSN  string
+	Type             string
+	DSN              string
+	Schemas          []string // postgres schemas to inspect, defaults to public
+	MigrationsSchema string   // postgres schema holding vagabond_migrations
+	StatementTimeout time.Duration
+	LockTimeout      time.Duration // busy_timeout on sqlite
+	Retry            retry.Policy  // for connecting and for transient migration errors
 }
 
-func New(cfg Config) (Driver, error) {
+func New(ctx context.Context, cfg Config) (Driver, error) {
 	var driver Driver
 	switch strings.ToLower(cfg.Type) {
 	case "sqlite":
-		driver = &SQLite{}
+		driver = &SQLite{busyTimeout: cfg.LockTimeout, statementTimeout: cfg.StatementTimeout}
 	case "postgres":
-		driver = &Postgres{}
+		driver = &Postgres{
+			schemas:          cfg.Schemas,
+			migrationsSchema: cfg.MigrationsSchema,
+			statementTimeout: cfg.StatementTimeout,
+			lockTimeout:      cfg.LockTimeout,
+		}
 	default:
 		return nil, fmt.Errorf("unsupported database: %s", cfg.Type)
 	}
 
-	if err := driver.Connect(cfg.DSN); err != nil {
+	connect := func() error { return driver.Connect(ctx, cfg.DSN) }
+	if err := retry.Do(ctx, cfg.Retry, slog.Default(), "connect", IsTransient, connect); err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 
@@ -1,11 +1,28 @@
 package db
 
+import (
+	"context"
+	"database/sql"
+)
+
 type Driver interface {
-	Connect(dsn string) error
+	Connect(ctx context.Context, dsn string) error
 	Close() error
-	GetAppliedMigrations() (map[string]bool, error)
-	GetAppliedMigrationsList() ([]string, error)
-	ExecuteMigration(filePath string) error
-	RollbackMigration(filePath, name string) error
-	DumpSchema() (string, error)
+	Lock(ctx context.Context) error
+	Unlock() error
+	GetAppliedMigrations(ctx context.Context) (map[string]bool, error)
+	GetAppliedMigrationsList(ctx context.Context) ([]string, error)
+	// ApplyMigration runs an up migration and records it under id.
+	ApplyMigration(ctx context.Context, id, content string) error
+	// RevertMigration runs a down migration and removes the record of id.
+	RevertMigration(ctx context.Context, id, content string) error
+	Exec(ctx context.Context, query string) error
+	// Query reads from tables vagabond doesn't own, such as the history
+	// table of another migration tool.
+	Query(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	// RecordMigration marks id as applied without running it.
+	RecordMigration(ctx context.Context, id string) error
+	DumpSchema(ctx context.Context) (string, error)
+	InspectSchema(ctx context.Context) (*Schema, error)
+	InspectDDL(ctx context.Context, ddl string) (*Schema, error)
 }
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", context.Canceled, false},
+		{"deadline", fmt.Errorf("connect: %w", context.DeadlineExceeded), false},
+		{"plain error", errors.New("syntax error"), false},
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, true},
+		{"lock not available", fmt.Errorf("apply: %w", &pq.Error{Code: "55P03"}), true},
+		{"server starting up", &pq.Error{Code: "57P03"}, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"syntax error", &pq.Error{Code: "42601"}, false},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"sqlite busy", sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{"sqlite locked", fmt.Errorf("apply: %w", sqlite3.Error{Code: sqlite3.ErrLocked}), true},
+		{"sqlite constraint", sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+		{"network error", &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, true},
+		{"bad connection", driver.ErrBadConn, true},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{"connection reset", syscall.ECONNRESET, true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	migrationsTable = "vagabond_migrations"
+	DefaultSchema   = "public"
+)
+
+type Schema struct {
+	Tables    []Table    `json:"tables"`
+	Enums     []Enum     `json:"enums"`
+	Sequences []Sequence `json:"sequences,omitempty"`
+}
+
+type Table struct {
+	Schema      string       `json:"schema,omitempty"`
+	Name        string       `json:"name"`
+	Comment     string       `json:"comment,omitempty"`
+	Columns     []Column     `json:"columns"`
+	PrimaryKey  []string     `json:"primary_key"`
+	UniqueKeys  []UniqueKey  `json:"unique_keys"`
+	ForeignKeys []ForeignKey `json:"foreign_keys"`
+	Checks      []Check      `json:"checks,omitempty"`
+	Indexes     []Index      `json:"indexes"`
+
+	// Definition is the CREATE TABLE statement the database keeps, sqlite
+	// only. It holds what the model doesn't, like AUTOINCREMENT.
+	Definition string `json:"-"`
+}
+
+type Column struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Nullable bool    `json:"nullable"`
+	Default  *string `json:"default"`
+	Identity string  `json:"identity,omitempty"` // ALWAYS or BY DEFAULT for postgres identity columns
+	Comment  string  `json:"comment,omitempty"`
+}
+
+type UniqueKey struct {
+	Name    string   `json:"name,omitempty"`
+	Columns []string `json:"columns"`
+}
+
+type ForeignKey struct {
+	Name       string   `json:"name,omitempty"`
+	Columns    []string `json:"columns"`
+	RefSchema  string   `json:"ref_schema,omitempty"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+}
+
+type Check struct {
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression"`
+}
+
+type Index struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	Unique     bool     `json:"unique"`
+	Definition string   `json:"definition"`
+}
+
+type Enum struct {
+	Schema string   `json:"schema,omitempty"`
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Sequence is a postgres sequence that isn't created along with a column,
+// serial and identity columns bring their own.
+type Sequence struct {
+	Schema    string `json:"schema,omitempty"`
+	Name      string `json:"name"`
+	Start     int64  `json:"start"`
+	Increment int64  `json:"increment"`
+}
+
+func (s *Schema) Table(name string) (Table, bool) {
+	for _, t := range s.Tables {
+		if t.FullName() == name {
+			return t, true
+		}
+	}
+	return Table{}, false
+}
+
+func (s *Schema) Sequence(name string) (Sequence, bool) {
+	for _, seq := range s.Sequences {
+		if seq.FullName() == name {
+			return seq, true
+		}
+	}
+	return Sequence{}, false
+}
+
+// OrderTables orders tables so that the ones referenced by a foreign key
+// come before the tables pointing at them, keeping the given order otherwise.
+func OrderTables(tables []Table) []Table {
+	byName := map[string]Table{}
+	for _, t := range tables {
+		byName[t.FullName()] = t
+	}
+
+	var ordered []Table
+	visited := map[string]bool{}
+	var visit func(t Table)
+	visit = func(t Table) {
+		if visited[t.FullName()] {
+			return
+		}
+		visited[t.FullName()] = true
+		for _, fk := range t.ForeignKeys {
+			if dep, ok := byName[fk.RefFullName()]; ok {
+				visit(dep)
+			}
+		}
+		ordered = append(ordered, t)
+	}
+	for _, t := range tables {
+		visit(t)
+	}
+	return ordered
+}
+
+func (s *Schema) Enum(name string) (Enum, bool) {
+	for _, e := range s.Enums {
+		if fullName(e.Schema, e.Name) == name {
+			return e, true
+		}
+	}
+	return Enum{}, false
+}
+
+// fullName leaves out the default postgres schema so that single schema
+// databases keep using plain table names.
+func fullName(schema, name string) string {
+	if schema == "" || schema == DefaultSchema {
+		return name
+	}
+	return schema + "." + name
+}
+
+func qualifiedName(schema, name string) string {
+	if schema == "" {
+		return QuoteIdent(name)
+	}
+	return QuoteIdent(schema) + "." + QuoteIdent(name)
+}
+
+func (t Table) FullName() string {
+	return fullName(t.Schema, t.Name)
+}
+
+func (t Table) QualifiedName() string {
+	return qualifiedName(t.Schema, t.Name)
+}
+
+func (fk ForeignKey) RefFullName() string {
+	return fullName(fk.RefSchema, fk.RefTable)
+}
+
+func (e Enum) FullName() string {
+	return fullName(e.Schema, e.Name)
+}
+
+func (e Enum) QualifiedName() string {
+	return qualifiedName(e.Schema, e.Name)
+}
+
+func (seq Sequence) FullName() string {
+	return fullName(seq.Schema, seq.Name)
+}
+
+func (seq Sequence) QualifiedName() string {
+	return qualifiedName(seq.Schema, seq.Name)
+}
+
+func (idx Index) QualifiedName(t Table) string {
+	return qualifiedName(t.Schema, idx.Name)
+}
+
+func (t Table) Column(name string) (Column, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// CreateStatement returns the statement creating the table, the one the
+// database keeps when there is one.
+func (t Table) CreateStatement() string {
+	if t.Definition != "" {
+		return t.Definition
+	}
+	var lines []string
+	for _, c := range t.Columns {
+		lines = append(lines, "  "+c.Definition())
+	}
+	if len(t.PrimaryKey) > 0 {
+		lines = append(lines, fmt.Sprintf("  PRIMARY KEY (%s)", QuoteIdentList(t.PrimaryKey)))
+	}
+	for _, u := range t.UniqueKeys {
+		lines = append(lines, "  "+u.Definition())
+	}
+	for _, fk := range t.ForeignKeys {
+		lines = append(lines, "  "+fk.Definition())
+	}
+	for _, check := range t.Checks {
+		lines = append(lines, "  "+check.Definition())
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", t.QualifiedName(), strings.Join(lines, ",\n"))
+}
+
+func (c Column) Definition() string {
+	def := fmt.Sprintf("%s %s", QuoteIdent(c.Name), c.Type)
+	if c.Default != nil {
+		def += " DEFAULT " + *c.Default
+	}
+	if c.Identity != "" {
+		def += " GENERATED " + c.Identity + " AS IDENTITY"
+	}
+	if !c.Nullable {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+func (u UniqueKey) Definition() string {
+	return constraintPrefix(u.Name) + fmt.Sprintf("UNIQUE (%s)", QuoteIdentList(u.Columns))
+}
+
+func (fk ForeignKey) Definition() string {
+	def := constraintPrefix(fk.Name) + fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", QuoteIdentList(fk.Columns), qualifiedName(fk.RefSchema, fk.RefTable))
+	if len(fk.RefColumns) > 0 {
+		def += fmt.Sprintf(" (%s)", QuoteIdentList(fk.RefColumns))
+	}
+	return def
+}
+
+func (c Check) Definition() string {
+	return constraintPrefix(c.Name) + "CHECK (" + c.Expression + ")"
+}
+
+func (seq Sequence) CreateStatement() string {
+	stmt := "CREATE SEQUENCE " + seq.QualifiedName()
+	if seq.Increment != 0 && seq.Increment != 1 {
+		stmt += fmt.Sprintf(" INCREMENT BY %d", seq.Increment)
+	}
+	if seq.Start != 0 && seq.Start != 1 {
+		stmt += fmt.Sprintf(" START WITH %d", seq.Start)
+	}
+	return stmt
+}
+
+func (e Enum) CreateStatement() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = QuoteLiteral(v)
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", e.QualifiedName(), strings.Join(values, ", "))
+}
+
+func constraintPrefix(name string) string {
+	if name == "" {
+		return ""
+	}
+	return "CONSTRAINT " + QuoteIdent(name) + " "
+}
+
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func QuoteIdentList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// parenthesized returns what's inside the parenthesis s starts with and the
+// rest of s after the closing one. Parenthesis in quoted strings and
+// identifiers don't count.
+func parenthesized(s string) (inside, rest string, ok bool) {
+	if !strings.HasPrefix(s, "(") {
+		return "", s, false
+	}
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], s[i+1:], true
+			}
+		}
+	}
+	return "", s, false
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParenthesized(t *testing.T) {
+	tests := []struct {
+		s      string
+		inside string
+		rest   string
+		ok     bool
+	}{
+		{"(a > 0)", "a > 0", "", true},
+		{"((price > (0)::numeric)) NOT VALID", "(price > (0)::numeric)", " NOT VALID", true},
+		{"(name != ')') AND x", "name != ')'", " AND x", true},
+		{`("weird)" > 0)`, `"weird)" > 0`, "", true},
+		{"(unbalanced", "", "(unbalanced", false},
+		{"no parenthesis", "", "no parenthesis", false},
+	}
+
+	for _, tt := range tests {
+		inside, rest, ok := parenthesized(tt.s)
+		if inside != tt.inside || rest != tt.rest || ok != tt.ok {
+			t.Errorf("parenthesized(%q) = %q, %q, %v, want %q, %q, %v", tt.s, inside, rest, ok, tt.inside, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestColumnDefinition(t *testing.T) {
+	def := "now()"
+	tests := []struct {
+		col  Column
+		want string
+	}{
+		{Column{Name: "id", Type: "bigint", Identity: "ALWAYS"}, `"id" bigint GENERATED ALWAYS AS IDENTITY NOT NULL`},
+		{Column{Name: "id", Type: "integer", Identity: "BY DEFAULT"}, `"id" integer GENERATED BY DEFAULT AS IDENTITY NOT NULL`},
+		{Column{Name: "id", Type: "serial"}, `"id" serial NOT NULL`},
+		{Column{Name: "at", Type: "timestamptz", Default: &def, Nullable: true}, `"at" timestamptz DEFAULT now()`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.col.Definition(); got != tt.want {
+			t.Errorf("Definition() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSequenceCreateStatement(t *testing.T) {
+	tests := []struct {
+		seq  Sequence
+		want string
+	}{
+		{Sequence{Name: "ids", Start: 1, Increment: 1}, `CREATE SEQUENCE "ids"`},
+		{Sequence{Schema: "billing", Name: "invoices", Start: 1000, Increment: 10}, `CREATE SEQUENCE "billing"."invoices" INCREMENT BY 10 START WITH 1000`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.seq.CreateStatement(); got != tt.want {
+			t.Errorf("CreateStatement() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestOrderTables(t *testing.T) {
+	table := func(name string, refs ...string) Table {
+		t := Table{Name: name}
+		for _, ref := range refs {
+			t.ForeignKeys = append(t.ForeignKeys, ForeignKey{Columns: []string{ref + "_id"}, RefTable: ref, RefColumns: []string{"id"}})
+		}
+		return t
+	}
+
+	tests := []struct {
+		name   string
+		tables []Table
+		want   string
+	}{
+		{"independent", []Table{table("b"), table("a")}, "b a"},
+		{"referenced later", []Table{table("invoices", "users"), table("users")}, "users invoices"},
+		{"chain", []Table{table("c", "b"), table("b", "a"), table("a")}, "a b c"},
+		{"outside reference", []Table{table("posts", "accounts")}, "posts"},
+		{"self reference", []Table{table("nodes", "nodes")}, "nodes"},
+		{"cycle", []Table{table("a", "b"), table("b", "a")}, "b a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, table := range OrderTables(tt.tables) {
+				names = append(names, table.Name)
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("OrderTables() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
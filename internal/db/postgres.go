@@ -1,41 +1,82 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
-	"path/filepath"
+	"hash/fnv"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jxdones/vagabond/internal/sqlscript"
+	"github.com/lib/pq"
 )
 
 type Postgres struct {
-	conn *sql.DB
+	conn             *sql.DB
+	lockConn         *sql.Conn
+	schemas          []string
+	migrationsSchema string
+	statementTimeout time.Duration
+	lockTimeout      time.Duration
 }
 
-func (p *Postgres) Connect(dsn string) error {
+func (p *Postgres) Connect(ctx context.Context, dsn string) error {
 	var err error
 	conn, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// sql.Open doesn't connect, the ping finds out whether the database is reachable
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
 	p.conn = conn
-	return p.createMigrationsTable()
+	if err := p.createMigrationsTable(ctx); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
 }
 
-func (p *Postgres) createMigrationsTable() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS vagabond_migrations (
+const migrationsTableDDL = `
+	CREATE TABLE IF NOT EXISTS %s (
 		id SERIAL PRIMARY KEY,
 		migration_id VARCHAR(255) NOT NULL UNIQUE,
 		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	)`
-	_, err := p.conn.Exec(query)
+
+func (p *Postgres) createMigrationsTable(ctx context.Context) error {
+	if p.migrationsSchema != "" {
+		if _, err := p.conn.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+QuoteIdent(p.migrationsSchema)); err != nil {
+			return err
+		}
+	}
+	_, err := p.conn.ExecContext(ctx, fmt.Sprintf(migrationsTableDDL, p.migrationsTable()))
 	return err
 }
 
+// migrationsTable is left unqualified unless a schema was configured, so it
+// follows the search path like it always did.
+func (p *Postgres) migrationsTable() string {
+	if p.migrationsSchema == "" {
+		return migrationsTable
+	}
+	return qualifiedName(p.migrationsSchema, migrationsTable)
+}
+
+func (p *Postgres) schemaNames() []string {
+	if len(p.schemas) == 0 {
+		return []string{DefaultSchema}
+	}
+	return p.schemas
+}
+
 func (p *Postgres) Close() error {
 	if p.conn == nil {
 		return fmt.Errorf("no open connection to close")
@@ -43,8 +84,55 @@ func (p *Postgres) Close() error {
 	return p.conn.Close()
 }
 
-func (p *Postgres) GetAppliedMigrations() (map[string]bool, error) {
-	rows, err := p.conn.Query("SELECT migration_id FROM vagabond_migrations ORDER BY applied_at ASC")
+// Lock takes a session level advisory lock on a dedicated connection, keyed on
+// the migrations table so that tenants sharing a database do not block each other.
+func (p *Postgres) Lock(ctx context.Context) error {
+	conn, err := p.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	// lock_timeout also bounds the wait for the advisory lock
+	if p.lockTimeout > 0 {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", p.lockTimeout.Milliseconds())); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", p.lockKey()); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	p.lockConn = conn
+	return nil
+}
+
+// Unlock runs even after the run was cancelled, so it doesn't take a context.
+func (p *Postgres) Unlock() error {
+	if p.lockConn == nil {
+		return nil
+	}
+	defer func() {
+		p.lockConn.Close()
+		p.lockConn = nil
+	}()
+	_, err := p.lockConn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", p.lockKey())
+	// the connection goes back to the pool, without the lock_timeout Lock set
+	if p.lockTimeout > 0 {
+		if _, resetErr := p.lockConn.ExecContext(context.Background(), "RESET lock_timeout"); err == nil {
+			err = resetErr
+		}
+	}
+	return err
+}
+
+func (p *Postgres) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(p.migrationsTable()))
+	return int64(h.Sum64())
+}
+
+func (p *Postgres) GetAppliedMigrations(ctx context.Context) (map[string]bool, error) {
+	rows, err := p.conn.QueryContext(ctx, "SELECT migration_id FROM "+p.migrationsTable()+" ORDER BY applied_at ASC")
 	if err != nil {
 		return nil, err
 	}
@@ -61,8 +149,8 @@ func (p *Postgres) GetAppliedMigrations() (map[string]bool, error) {
 	return applied, nil
 }
 
-func (p *Postgres) GetAppliedMigrationsList() ([]string, error) {
-	rows, err := p.conn.Query("SELECT migration_id FROM vagabond_migrations ORDER BY applied_at ASC")
+func (p *Postgres) GetAppliedMigrationsList(ctx context.Context) ([]string, error) {
+	rows, err := p.conn.QueryContext(ctx, "SELECT migration_id FROM "+p.migrationsTable()+" ORDER BY applied_at ASC")
 	if err != nil {
 		return nil, err
 	}
@@ -79,25 +167,90 @@ func (p *Postgres) GetAppliedMigrationsList() ([]string, error) {
 	return list, nil
 }
 
-func (p *Postgres) ExecuteMigration(filePath string) error {
-	data, err := os.ReadFile(filePath)
+// begin starts a migration transaction with the configured timeouts, scoped
+// to the transaction so they don't leak into the pooled connection.
+func (p *Postgres) begin(ctx context.Context) (*sql.Tx, error) {
+	tx, err := p.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
+		return nil, err
+	}
+
+	for _, setting := range p.timeouts() {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL %s = %d", setting.name, setting.timeout.Milliseconds())); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to set %s: %w", setting.name, err)
+		}
 	}
+	return tx, nil
+}
 
-	tx, err := p.conn.Begin()
+type timeoutSetting struct {
+	name    string
+	timeout time.Duration
+}
+
+func (p *Postgres) timeouts() []timeoutSetting {
+	var settings []timeoutSetting
+	for _, setting := range []timeoutSetting{
+		{"statement_timeout", p.statementTimeout},
+		{"lock_timeout", p.lockTimeout},
+	} {
+		if setting.timeout > 0 {
+			settings = append(settings, setting)
+		}
+	}
+	return settings
+}
+
+// execWithoutTransaction runs a migration annotated with
+// "-- vagabond:no-transaction" one statement at a time, for statements such
+// as CREATE INDEX CONCURRENTLY that postgres refuses in a transaction block.
+// A failure leaves the statements before it applied.
+func (p *Postgres) execWithoutTransaction(ctx context.Context, content, record, id string) error {
+	conn, err := p.conn.Conn(ctx)
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
+
+	// the timeouts are session settings here, reset them before the
+	// connection goes back to the pool
+	for _, setting := range p.timeouts() {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET %s = %d", setting.name, setting.timeout.Milliseconds())); err != nil {
+			return fmt.Errorf("failed to set %s: %w", setting.name, err)
+		}
+		defer conn.ExecContext(context.Background(), "RESET "+setting.name)
+	}
+
+	for _, statement := range sqlscript.Split(content) {
+		if _, err := conn.ExecContext(ctx, statement.Raw); err != nil {
+			return fmt.Errorf("failed to execute migration at line %d: %w", statement.Line, err)
+		}
+	}
 
-	if _, err := tx.Exec(string(data)); err != nil {
+	if _, err := conn.ExecContext(ctx, record, id); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	return nil
+}
+
+// ApplyMigration runs the SQL of an up migration and records it under id.
+func (p *Postgres) ApplyMigration(ctx context.Context, id, content string) error {
+	if sqlscript.HasAnnotation(content, "no-transaction") {
+		return p.execWithoutTransaction(ctx, content, "INSERT INTO "+p.migrationsTable()+" (migration_id) VALUES ($1)", id)
+	}
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, content); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to execute migration: %w", err)
 	}
 
-	_, migrationFile := filepath.Split(filePath)
-	migrationID := strings.TrimSuffix(migrationFile, ".sql")
-	_, err = tx.Exec("INSERT INTO vagabond_migrations (migration_id) VALUES ($1)", migrationID)
+	_, err = tx.ExecContext(ctx, "INSERT INTO "+p.migrationsTable()+" (migration_id) VALUES ($1)", id)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to record migration: %w", err)
@@ -106,24 +259,24 @@ func (p *Postgres) ExecuteMigration(filePath string) error {
 	return tx.Commit()
 }
 
-func (p *Postgres) RollbackMigration(filePath string, name string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
+// RevertMigration runs the SQL of a down migration and removes the record
+// of the up migration id.
+func (p *Postgres) RevertMigration(ctx context.Context, id, content string) error {
+	if sqlscript.HasAnnotation(content, "no-transaction") {
+		return p.execWithoutTransaction(ctx, content, "DELETE from "+p.migrationsTable()+" WHERE migration_id = $1", id)
 	}
 
-	tx, err := p.conn.Begin()
+	tx, err := p.begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(string(data)); err != nil {
+	if _, err := tx.ExecContext(ctx, content); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to execute migration: %w", err)
 	}
 
-	migrationID := strings.TrimSuffix(name, ".sql")
-	_, err = tx.Exec("DELETE from vagabond_migrations WHERE migration_id = $1", migrationID)
+	_, err = tx.ExecContext(ctx, "DELETE from "+p.migrationsTable()+" WHERE migration_id = $1", id)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to delete migration record: %w", err)
@@ -132,42 +285,73 @@ func (p *Postgres) RollbackMigration(filePath string, name string) error {
 	return tx.Commit()
 }
 
-func (p *Postgres) DumpSchema() (string, error) {
+func (p *Postgres) Query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return p.conn.QueryContext(ctx, query, args...)
+}
+
+func (p *Postgres) RecordMigration(ctx context.Context, id string) error {
+	_, err := p.conn.ExecContext(ctx, "INSERT INTO "+p.migrationsTable()+" (migration_id) VALUES ($1)", id)
+	return err
+}
+
+// Exec runs SQL that isn't a migration, such as a hook, without recording
+// anything, in a transaction bounded by the timeouts when there are any.
+func (p *Postgres) Exec(ctx context.Context, query string) error {
+	if len(p.timeouts()) == 0 {
+		_, err := p.conn.ExecContext(ctx, query)
+		return err
+	}
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) DumpSchema(ctx context.Context) (string, error) {
 	var schema strings.Builder
 
 	schema.WriteString("-- This file has been automatically generated based on the current database state.\n")
 	schema.WriteString("-- Manual modification of this file is not recommended. Use database migrations for schema changes.\n\n")
 
-	tables, err := p.getTables()
+	model, err := p.InspectSchema(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	for _, table := range tables {
-		createStmt, err := p.getCreateTableStmt(table)
-		if err != nil {
-			return "", err
+	for _, name := range p.schemaNames() {
+		if name != DefaultSchema {
+			schema.WriteString("CREATE SCHEMA IF NOT EXISTS " + QuoteIdent(name) + ";\n\n")
 		}
-		schema.WriteString(createStmt + ";\n\n")
 	}
 
-	indexes, err := p.getIndexes()
-	if err != nil {
-		return "", err
+	// enums go first so that the tables using them can be created
+	for _, enum := range model.Enums {
+		schema.WriteString(enum.CreateStatement() + ";\n\n")
 	}
-	for _, idx := range indexes {
-		schema.WriteString(idx + ";\n\n")
+
+	// so do the sequences the column defaults draw from
+	for _, sequence := range model.Sequences {
+		schema.WriteString(sequence.CreateStatement() + ";\n\n")
 	}
 
-	enums, err := p.getEnums()
-	if err != nil {
-		return "", err
+	for _, table := range OrderTables(model.Tables) {
+		schema.WriteString(table.CreateStatement() + ";\n\n")
 	}
-	for _, enum := range enums {
-		schema.WriteString(enum + ";\n\n")
+
+	for _, table := range model.Tables {
+		for _, idx := range table.Indexes {
+			schema.WriteString(idx.Definition + ";\n\n")
+		}
 	}
 
-	migrationRows, err := p.conn.Query(`SELECT id, migration_id FROM vagabond_migrations ORDER BY id`)
+	migrationRows, err := p.conn.QueryContext(ctx, "SELECT id, migration_id FROM "+p.migrationsTable()+" ORDER BY id")
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch applied migrations: %w", err)
 	}
@@ -184,7 +368,7 @@ func (p *Postgres) DumpSchema() (string, error) {
 	}
 
 	if len(values) > 0 {
-		schema.WriteString("INSERT INTO vagabond_migrations (id, migration_id) VALUES\n\t")
+		schema.WriteString("INSERT INTO " + p.migrationsTable() + " (id, migration_id) VALUES\n\t")
 		schema.WriteString(strings.Join(values, ",\n\t"))
 		schema.WriteString(";\n")
 	}
@@ -192,13 +376,192 @@ func (p *Postgres) DumpSchema() (string, error) {
 	return strings.TrimSpace(schema.String()), nil
 }
 
-func (p *Postgres) getTables() ([]string, error) {
-	rows, err := p.conn.Query(`
+func (p *Postgres) InspectSchema(ctx context.Context) (*Schema, error) {
+	schema := &Schema{}
+	for _, namespace := range p.schemaNames() {
+		inspected, err := inspectPostgres(ctx, p.conn, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect schema %s: %w", namespace, err)
+		}
+		schema.Tables = append(schema.Tables, inspected.Tables...)
+		schema.Enums = append(schema.Enums, inspected.Enums...)
+		schema.Sequences = append(schema.Sequences, inspected.Sequences...)
+	}
+	return schema, nil
+}
+
+func (p *Postgres) InspectDDL(ctx context.Context, ddl string) (*Schema, error) {
+	tx, err := p.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// postgres DDL is transactional, rolling back discards the scratch schemas
+	defer tx.Rollback()
+
+	// every configured schema gets a scratch schema, and the DDL, often a
+	// dump qualifying its objects, is pointed at them so that it doesn't
+	// touch the real ones
+	names := p.schemaNames()
+	stamp := time.Now().UnixNano()
+	scratches := make([]string, len(names))
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		scratches[i] = fmt.Sprintf("vagabond_scratch_%d_%d", stamp, i)
+		quoted[i] = QuoteIdent(scratches[i])
+		if _, err := tx.ExecContext(ctx, "CREATE SCHEMA "+quoted[i]); err != nil {
+			return nil, fmt.Errorf("failed to create scratch schema for %s: %w", name, err)
+		}
+		ddl = toScratch(ddl, name, scratches[i])
+	}
+	if p.migrationsSchema != "" && !slices.Contains(names, p.migrationsSchema) {
+		ddl = toScratch(ddl, p.migrationsSchema, scratches[0])
+	}
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+quoted[0]); err != nil {
+		return nil, fmt.Errorf("failed to set search path: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(migrationsTableDDL, migrationsTable)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, ddl); err != nil {
+		return nil, fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	real := map[string]string{}
+	var replacements []string
+	for i, scratch := range scratches {
+		real[scratch] = names[i]
+		// types and defaults from other schemas are qualified the way
+		// postgres does it, like the ones inspected on the real database
+		replacements = append(replacements, quoteIdentIfNeeded(scratch)+".", quoteIdentIfNeeded(names[i])+".")
+	}
+	fromScratch := strings.NewReplacer(replacements...)
+
+	schema := &Schema{}
+	for i, scratch := range scratches {
+		inspected, err := inspectPostgres(ctx, tx, scratch)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range inspected.Tables {
+			table.Schema = names[i]
+			for j, col := range table.Columns {
+				table.Columns[j].Type = fromScratch.Replace(col.Type)
+				if col.Default != nil {
+					def := fromScratch.Replace(*col.Default)
+					table.Columns[j].Default = &def
+				}
+			}
+			for j, fk := range table.ForeignKeys {
+				if name, ok := real[fk.RefSchema]; ok {
+					table.ForeignKeys[j].RefSchema = name
+				}
+			}
+			for j, check := range table.Checks {
+				table.Checks[j].Expression = fromScratch.Replace(check.Expression)
+			}
+			for j, idx := range table.Indexes {
+				table.Indexes[j].Definition = requalifyIndex(idx.Definition, scratch, names[i])
+			}
+			schema.Tables = append(schema.Tables, table)
+		}
+		for _, enum := range inspected.Enums {
+			enum.Schema = names[i]
+			schema.Enums = append(schema.Enums, enum)
+		}
+		for _, seq := range inspected.Sequences {
+			seq.Schema = names[i]
+			schema.Sequences = append(schema.Sequences, seq)
+		}
+	}
+	return schema, nil
+}
+
+var plainIdent = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+
+// quoteIdentIfNeeded quotes name like postgres' quote_ident, only when it
+// isn't a plain lowercase identifier.
+func quoteIdentIfNeeded(name string) string {
+	if plainIdent.MatchString(name) {
+		return name
+	}
+	return QuoteIdent(name)
+}
+
+// toScratch points the references to schema in ddl, quoted or not, at the
+// scratch schema.
+func toScratch(ddl, schema, scratch string) string {
+	target := QuoteIdent(scratch) + "."
+	ddl = strings.ReplaceAll(ddl, QuoteIdent(schema)+".", target)
+	unquoted := regexp.MustCompile(`(^|[^\w."])` + regexp.QuoteMeta(schema) + `\.`)
+	return unquoted.ReplaceAllString(ddl, "${1}"+target)
+}
+
+// requalifyIndex moves an index definition from one schema to another.
+// pg_get_indexdef always qualifies the table, quoting the schema only when
+// it has to.
+func requalifyIndex(definition, from, to string) string {
+	for _, on := range []string{" ON ", " ON ONLY "} {
+		for _, qualifier := range []string{from + ".", QuoteIdent(from) + "."} {
+			if i := strings.Index(definition, on+qualifier); i >= 0 {
+				return definition[:i] + on + QuoteIdent(to) + "." + definition[i+len(on)+len(qualifier):]
+			}
+		}
+	}
+	return definition
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func inspectPostgres(ctx context.Context, q queryer, namespace string) (*Schema, error) {
+	tables, err := postgresTables(ctx, q, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tables: %w", err)
+	}
+
+	schema := &Schema{}
+	serials := map[string]bool{}
+	for _, name := range tables {
+		table := Table{Schema: namespace, Name: name}
+		if table.Comment, err = postgresTableComment(ctx, q, namespace, name); err != nil {
+			return nil, fmt.Errorf("failed to fetch comment of %s: %w", name, err)
+		}
+		if table.Columns, err = postgresColumns(ctx, q, namespace, name, serials); err != nil {
+			return nil, fmt.Errorf("failed to fetch columns of %s: %w", name, err)
+		}
+		if err := postgresConstraints(ctx, q, namespace, &table); err != nil {
+			return nil, fmt.Errorf("failed to fetch constraints of %s: %w", name, err)
+		}
+		if table.Indexes, err = postgresIndexes(ctx, q, namespace, name); err != nil {
+			return nil, fmt.Errorf("failed to fetch indexes of %s: %w", name, err)
+		}
+		schema.Tables = append(schema.Tables, table)
+	}
+
+	if schema.Enums, err = postgresEnums(ctx, q, namespace); err != nil {
+		return nil, fmt.Errorf("failed to fetch enums: %w", err)
+	}
+	sequences, err := postgresSequences(ctx, q, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sequences: %w", err)
+	}
+	for _, seq := range sequences {
+		if !serials[seq.Name] {
+			schema.Sequences = append(schema.Sequences, seq)
+		}
+	}
+	return schema, nil
+}
+
+func postgresTables(ctx context.Context, q queryer, namespace string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
 		SELECT tablename
 		FROM pg_tables
-		WHERE schemaname = 'public'
+		WHERE schemaname = $1 AND tablename != $2
 		ORDER BY tablename
-	`)
+	`, namespace, migrationsTable)
 	if err != nil {
 		return nil, err
 	}
@@ -210,165 +573,266 @@ func (p *Postgres) getTables() ([]string, error) {
 		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
-		if name == "_vagabond_migrations" {
-			continue
-		}
 		tables = append(tables, name)
 	}
-	return tables, nil
+	return tables, rows.Err()
 }
 
-func (p *Postgres) getCreateTableStmt(table string) (string, error) {
-	columns, err := p.getColumns(table)
-	if err != nil {
-		return "", err
-	}
-
-	constraints, err := p.getConstraints(table)
-	if err != nil {
-		return "", err
-	}
+func postgresTableComment(ctx context.Context, q queryer, namespace, table string) (string, error) {
+	var comment string
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(obj_description(c.oid, 'pg_class'), '')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2
+	`, namespace, table).Scan(&comment)
+	return comment, err
+}
 
-	return fmt.Sprintf("CREATE TABLE %q (\n%s\n%s)", table, strings.Join(columns, ",\n"), constraints), nil
+var serialTypes = map[string]string{
+	"smallint": "smallserial",
+	"integer":  "serial",
+	"bigint":   "bigserial",
 }
 
-func (p *Postgres) getColumns(table string) ([]string, error) {
-	rows, err := p.conn.Query(`
-		SELECT column_name, data_type, is_nullable, column_default
-		FROM information_schema.columns
-		WHERE table_schema = 'public' AND table_name = $1
-		ORDER BY ordinal_position
-	`, table)
+// postgresColumns reads the columns of a table. Columns defaulting to the
+// sequence serial creates for them come back as serial, which creates the
+// sequence again, and their sequence is added to serials.
+func postgresColumns(ctx context.Context, q queryer, namespace, table string, serials map[string]bool) ([]Column, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			a.attname,
+			format_type(a.atttypid, a.atttypmod),
+			NOT a.attnotnull,
+			pg_get_expr(d.adbin, d.adrelid),
+			CASE a.attidentity WHEN 'a' THEN 'ALWAYS' WHEN 'd' THEN 'BY DEFAULT' ELSE '' END,
+			COALESCE((
+				SELECT s.relname
+				FROM pg_depend dep
+				JOIN pg_class s ON s.oid = dep.objid AND s.relkind = 'S'
+				WHERE dep.classid = 'pg_class'::regclass AND dep.refobjid = a.attrelid
+				AND dep.refobjsubid = a.attnum AND dep.deptype = 'a'
+				LIMIT 1
+			), ''),
+			COALESCE(col_description(a.attrelid, a.attnum), '')
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = $1 AND c.relname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum
+	`, namespace, table)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var cols []string
+	var cols []Column
 	for rows.Next() {
-		var name, dataType, nullable string
-		var defaultVal *string
-
-		if err := rows.Scan(&name, &dataType, &nullable, &defaultVal); err != nil {
+		var col Column
+		var sequence string
+		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &col.Default, &col.Identity, &sequence, &col.Comment); err != nil {
 			return nil, err
 		}
-
-		col := fmt.Sprintf("  %q %s", name, dataType)
-		if defaultVal != nil {
-			col += fmt.Sprintf(" DEFAULT %s", *defaultVal)
-		}
-		if nullable == "NO" {
-			col += " NOT NULL"
+		if serial, ok := serialTypes[col.Type]; ok && isSerialDefault(col.Default, table, col.Name, sequence) {
+			col.Type, col.Default = serial, nil
+			serials[sequence] = true
 		}
 		cols = append(cols, col)
 	}
-	return cols, nil
+	return cols, rows.Err()
+}
+
+// isSerialDefault tells whether a column defaults to the sequence it owns,
+// named the way serial names it.
+func isSerialDefault(def *string, table, column, sequence string) bool {
+	if def == nil || sequence != table+"_"+column+"_seq" {
+		return false
+	}
+	return strings.HasPrefix(*def, "nextval('") && strings.HasSuffix(*def, quoteIdentIfNeeded(sequence)+"'::regclass)")
 }
 
-func (p *Postgres) getConstraints(table string) (string, error) {
-	rows, err := p.conn.Query(`
+func postgresConstraints(ctx context.Context, q queryer, namespace string, table *Table) error {
+	rows, err := q.QueryContext(ctx, `
 		SELECT
-			c.constraint_type,
-			c.constraint_name,
-			string_agg(cols.column_name, ',') AS columns,
-			fk.foreign_table_name,
-			string_agg(fk.foreign_column_name, ',') AS foreign_columns
-		FROM information_schema.table_constraints c
-		JOIN information_schema.constraint_column_usage cols
-			ON c.constraint_name = cols.constraint_name
-		LEFT JOIN (
-			SELECT
-				tc.constraint_name,
-				ccu.table_name AS foreign_table_name,
-				ccu.column_name AS foreign_column_name
-			FROM information_schema.referential_constraints rc
-			JOIN information_schema.table_constraints tc
-				ON rc.constraint_name = tc.constraint_name
-			JOIN information_schema.constraint_column_usage ccu
-				ON rc.unique_constraint_name = ccu.constraint_name
-		) fk ON c.constraint_name = fk.constraint_name
-		WHERE c.table_name = $1 AND c.table_schema = 'public'
-		GROUP BY c.constraint_type, c.constraint_name, fk.foreign_table_name
-	`, table)
+			con.conname,
+			con.contype,
+			ARRAY(
+				SELECT att.attname
+				FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute att ON att.attrelid = con.conrelid AND att.attnum = k.attnum
+				ORDER BY k.ord
+			)::text[],
+			COALESCE(fn.nspname, ''),
+			COALESCE(ft.relname, ''),
+			ARRAY(
+				SELECT att.attname
+				FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute att ON att.attrelid = con.confrelid AND att.attnum = k.attnum
+				ORDER BY k.ord
+			)::text[],
+			pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_class ft ON ft.oid = con.confrelid
+		LEFT JOIN pg_namespace fn ON fn.oid = ft.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2 AND con.contype IN ('p', 'u', 'f', 'c')
+		ORDER BY con.conname
+	`, namespace, table.Name)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer rows.Close()
 
-	var constraints []string
 	for rows.Next() {
-		var ctype, name, cols, ftable, fcols string
-		_ = rows.Scan(&ctype, &name, &cols, &ftable, &fcols)
+		var name, ctype, fschema, ftable, def string
+		var cols, fcols []string
+		if err := rows.Scan(&name, &ctype, pq.Array(&cols), &fschema, &ftable, pq.Array(&fcols), &def); err != nil {
+			return err
+		}
 
 		switch ctype {
-		case "PRIMARY KEY":
-			constraints = append(constraints, fmt.Sprintf("  ,PRIMARY KEY (%s)", quoteIdentList(cols)))
-		case "UNIQUE":
-			constraints = append(constraints, fmt.Sprintf("  ,UNIQUE (%s)", quoteIdentList(cols)))
-		case "FOREIGN KEY":
-			constraints = append(constraints, fmt.Sprintf("  ,FOREIGN KEY (%s) REFERENCES %s (%s)",
-				quoteIdentList(cols), ftable, quoteIdentList(fcols)))
+		case "p":
+			table.PrimaryKey = cols
+		case "u":
+			table.UniqueKeys = append(table.UniqueKeys, UniqueKey{Name: name, Columns: cols})
+		case "f":
+			table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+				Name:       name,
+				Columns:    cols,
+				RefSchema:  fschema,
+				RefTable:   ftable,
+				RefColumns: fcols,
+			})
+		case "c":
+			// pg_get_constraintdef gives CHECK (<expression>), maybe
+			// followed by NO INHERIT or NOT VALID
+			if expr, _, ok := parenthesized(strings.TrimPrefix(def, "CHECK ")); ok {
+				table.Checks = append(table.Checks, Check{Name: name, Expression: expr})
+			}
 		}
 	}
-	return strings.Join(constraints, "\n"), nil
+	return rows.Err()
 }
 
-func quoteIdentList(csv string) string {
-	parts := strings.Split(csv, ",")
-	for i, p := range parts {
-		parts[i] = fmt.Sprintf(`"%s"`, strings.TrimSpace(p))
+func postgresIndexes(ctx context.Context, q queryer, namespace, table string) ([]Index, error) {
+	// indexes backing primary key and unique constraints are part of the table definition
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			i.relname,
+			ix.indisunique,
+			pg_get_indexdef(ix.indexrelid),
+			ARRAY(
+				SELECT pg_get_indexdef(ix.indexrelid, k + 1, true)
+				FROM generate_subscripts(ix.indkey, 1) AS k
+				ORDER BY k
+			)::text[]
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = $1 AND t.relname = $2
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_constraint con
+			WHERE con.conindid = ix.indexrelid AND con.contype IN ('p', 'u', 'x')
+		)
+		ORDER BY i.relname
+	`, namespace, table)
+	if err != nil {
+		return nil, err
 	}
-	return strings.Join(parts, ", ")
+	defer rows.Close()
+
+	var indexes []Index
+	for rows.Next() {
+		var idx Index
+		if err := rows.Scan(&idx.Name, &idx.Unique, &idx.Definition, pq.Array(&idx.Columns)); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
 }
 
-func (p *Postgres) getEnums() ([]string, error) {
-	rows, err := p.conn.Query(`
-		SELECT t.typname, string_agg(e.enumlabel, ',' ORDER BY e.enumsortorder)
+func postgresEnums(ctx context.Context, q queryer, namespace string) ([]Enum, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT t.typname, ARRAY_AGG(e.enumlabel ORDER BY e.enumsortorder)::text[]
 		FROM pg_type t
 		JOIN pg_enum e ON t.oid = e.enumtypid
 		JOIN pg_namespace n ON n.oid = t.typnamespace
-		WHERE n.nspname = 'public'
+		WHERE n.nspname = $1
 		GROUP BY t.typname
-	`)
+		ORDER BY t.typname
+	`, namespace)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var enums []string
+	var enums []Enum
 	for rows.Next() {
-		var name, values string
-		_ = rows.Scan(&name, &values)
+		enum := Enum{Schema: namespace}
+		if err := rows.Scan(&enum.Name, pq.Array(&enum.Values)); err != nil {
+			return nil, err
+		}
+		enums = append(enums, enum)
+	}
+	return enums, rows.Err()
+}
 
-		vals := strings.Split(values, ",")
-		for i := range vals {
-			vals[i] = fmt.Sprintf("'%s'", vals[i])
+// postgresSequences reads the sequences of namespace, except the ones
+// identity columns create and own.
+func postgresSequences(ctx context.Context, q queryer, namespace string) ([]Sequence, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c.relname, s.seqstart, s.seqincrement
+		FROM pg_sequence s
+		JOIN pg_class c ON c.oid = s.seqrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend dep
+			WHERE dep.classid = 'pg_class'::regclass AND dep.objid = c.oid AND dep.deptype = 'i'
+		)
+		ORDER BY c.relname
+	`, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sequences []Sequence
+	for rows.Next() {
+		seq := Sequence{Schema: namespace}
+		if err := rows.Scan(&seq.Name, &seq.Start, &seq.Increment); err != nil {
+			return nil, err
 		}
-		enums = append(enums, fmt.Sprintf("CREATE TYPE %q AS ENUM (%s)", name, strings.Join(vals, ", ")))
+		sequences = append(sequences, seq)
 	}
-	return enums, nil
+	return sequences, rows.Err()
 }
 
-func (p *Postgres) getIndexes() ([]string, error) {
-	rows, err := p.conn.Query(`
-		SELECT indexname, indexdef
-		FROM pg_indexes
-		WHERE schemaname = 'public'
-		AND indexdef NOT ILIKE '%pkey%'
-		AND indexdef NOT ILIKE '%unique%'
-	`)
+func (p *Postgres) ListSchemas(ctx context.Context, query string) ([]string, error) {
+	if query == "" {
+		query = `
+			SELECT nspname FROM pg_namespace
+			WHERE nspname NOT LIKE 'pg_%' AND nspname != 'information_schema'
+			ORDER BY nspname`
+	}
+
+	rows, err := p.conn.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var indexes []string
+	var schemas []string
 	for rows.Next() {
-		var name, def string
-		if err := rows.Scan(&name, &def); err != nil {
+		var name string
+		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
-		indexes = append(indexes, def)
+		schemas = append(schemas, name)
 	}
-	return indexes, nil
+	return schemas, rows.Err()
 }
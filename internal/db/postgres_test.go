@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRequalifyIndex(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       string
+	}{
+		{
+			"CREATE INDEX users_name ON vagabond_scratch_1.users USING btree (name)",
+			`CREATE INDEX users_name ON "public".users USING btree (name)`,
+		},
+		{
+			`CREATE UNIQUE INDEX users_email ON "vagabond_scratch_1".users USING btree (lower(email))`,
+			`CREATE UNIQUE INDEX users_email ON "public".users USING btree (lower(email))`,
+		},
+		{
+			"CREATE INDEX events_at ON ONLY vagabond_scratch_1.events USING btree (at)",
+			`CREATE INDEX events_at ON ONLY "public".events USING btree (at)`,
+		},
+		{
+			"CREATE INDEX other ON app.users USING btree (name)",
+			"CREATE INDEX other ON app.users USING btree (name)",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := requalifyIndex(tt.definition, "vagabond_scratch_1", "public"); got != tt.want {
+			t.Errorf("requalifyIndex(%q) = %q, want %q", tt.definition, got, tt.want)
+		}
+	}
+}
+
+func TestToScratch(t *testing.T) {
+	tests := []struct {
+		ddl  string
+		want string
+	}{
+		{
+			`CREATE TABLE "public"."users" (id int);`,
+			`CREATE TABLE "vagabond_scratch_1"."users" (id int);`,
+		},
+		{
+			"CREATE INDEX users_name ON public.users USING btree (name);",
+			`CREATE INDEX users_name ON "vagabond_scratch_1".users USING btree (name);`,
+		},
+		{
+			"ALTER TABLE a ADD FOREIGN KEY (b) REFERENCES public.b (id);",
+			`ALTER TABLE a ADD FOREIGN KEY (b) REFERENCES "vagabond_scratch_1".b (id);`,
+		},
+		{
+			// other schemas and columns named like the schema are left alone
+			"CREATE VIEW v AS SELECT t.public FROM app.t, mypublic.u, x.public.y;",
+			"CREATE VIEW v AS SELECT t.public FROM app.t, mypublic.u, x.public.y;",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := toScratch(tt.ddl, "public", "vagabond_scratch_1"); got != tt.want {
+			t.Errorf("toScratch(%q) = %q, want %q", tt.ddl, got, tt.want)
+		}
+	}
+}
+
+// TestPostgresDumpRoundTrip replays a dump on the database it came from,
+// emptied, and expects the same schema back. It needs a disposable
+// PostgreSQL database in VAGABOND_TEST_POSTGRES_DSN, whose public schema it
+// drops.
+func TestPostgresDumpRoundTrip(t *testing.T) {
+	dsn := os.Getenv("VAGABOND_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("VAGABOND_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx := context.Background()
+	p := &Postgres{}
+	if err := p.Connect(ctx, dsn); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	reset := func() {
+		t.Helper()
+		if err := p.Exec(ctx, "DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.createMigrationsTable(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dump := func() string {
+		t.Helper()
+		dump, err := p.DumpSchema(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dump
+	}
+
+	reset()
+	if err := p.Exec(ctx, `
+CREATE TYPE mood AS ENUM ('happy', 'sad');
+CREATE SEQUENCE invoice_numbers START WITH 1000 INCREMENT BY 10;
+CREATE TABLE users (
+	id serial PRIMARY KEY,
+	email text NOT NULL UNIQUE CHECK (email LIKE '%@%'),
+	mood mood
+);
+CREATE TABLE invoices (
+	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	number bigint NOT NULL DEFAULT nextval('invoice_numbers'),
+	user_id integer NOT NULL REFERENCES users (id),
+	total numeric CONSTRAINT positive_total CHECK (total > 0)
+);
+CREATE INDEX invoices_user_id ON invoices (user_id);`); err != nil {
+		t.Fatal(err)
+	}
+	original := dump()
+
+	reset()
+	if err := p.Exec(ctx, original); err != nil {
+		t.Fatalf("replaying the dump: %v\n%s", err, original)
+	}
+	if replayed := dump(); replayed != original {
+		t.Errorf("replayed dump differs:\n%s\nwant\n%s", replayed, original)
+	}
+}
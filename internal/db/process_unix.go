@@ -0,0 +1,15 @@
+//go:build unix
+
+package db
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive tells whether a process of this host exists. A process of
+// another user is alive too, signalling it is only not permitted.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
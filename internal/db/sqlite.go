@@ -1,28 +1,66 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type SQLite struct {
-	conn *sql.DB
+	conn             *sql.DB
+	busyTimeout      time.Duration
+	statementTimeout time.Duration
+	lockOwner        *lockHolder // set while Lock holds the lock row
 }
 
-func (s *SQLite) Connect(dsn string) error {
+func (s *SQLite) Connect(ctx context.Context, dsn string) error {
+	// busy_timeout is per connection, so it goes in the DSN to reach every
+	// connection of the pool
+	if s.busyTimeout > 0 {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += fmt.Sprintf("%s_busy_timeout=%d", sep, s.busyTimeout.Milliseconds())
+	}
+
 	var err error
 	conn, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// sql.Open doesn't connect, the ping finds out whether the database is reachable
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
 	s.conn = conn
-	return s.createMigrationsTable()
+	if err := s.createMigrationsTable(ctx); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// NewSQLite wraps an open connection, creating the migrations table if
+// needed. Closing the driver closes conn.
+func NewSQLite(ctx context.Context, conn *sql.DB) (*SQLite, error) {
+	s := &SQLite{conn: conn}
+	if err := s.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 func (s *SQLite) Close() error {
@@ -32,19 +70,122 @@ func (s *SQLite) Close() error {
 	return s.conn.Close()
 }
 
-func (s *SQLite) createMigrationsTable() error {
+const (
+	lockTable = "vagabond_lock"
+	lockPoll  = 200 * time.Millisecond
+	// lockWait bounds the wait for the migration lock without a busy
+	// timeout, a run killed on another host leaves its row behind for good
+	lockWait = time.Minute
+)
+
+// lockHolder is the run holding the lock row.
+type lockHolder struct {
+	pid      int
+	host     string
+	lockedAt string
+}
+
+func (h lockHolder) String() string {
+	return fmt.Sprintf("pid %d on %s since %s", h.pid, h.host, h.lockedAt)
+}
+
+// Lock claims the single row of the lock table, waiting while another run
+// holds it, up to the busy timeout or lockWait. sqlite has no session
+// locks: a run killed before Unlock leaves its row behind. A row left by a
+// process of this host that is gone is taken over, the holder is named
+// when giving up otherwise.
+func (s *SQLite) Lock(ctx context.Context) error {
+	_, err := s.conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS `+lockTable+` (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		pid INTEGER NOT NULL,
+		host TEXT NOT NULL,
+		locked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create lock table: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	owner := lockHolder{pid: os.Getpid(), host: host}
+
+	wait := s.busyTimeout
+	if wait <= 0 {
+		wait = lockWait
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for waiting := false; ; {
+		_, err := s.conn.ExecContext(ctx, "INSERT INTO "+lockTable+" (id, pid, host) VALUES (1, ?, ?)", owner.pid, owner.host)
+		if err == nil {
+			s.lockOwner = &owner
+			return nil
+		}
+		var sqliteErr sqlite3.Error
+		if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+
+		holder, err := s.lockHolder(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // released in between
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read migration lock: %w", err)
+		}
+		if holder.host == owner.host && !processAlive(holder.pid) {
+			slog.Warn("Taking over the migration lock of a run that is gone", "held_by", holder.String(), "table", lockTable)
+			_, err := s.conn.ExecContext(ctx, "DELETE FROM "+lockTable+" WHERE id = 1 AND pid = ? AND host = ?", holder.pid, holder.host)
+			if err != nil {
+				return fmt.Errorf("failed to remove stale migration lock: %w", err)
+			}
+			continue
+		}
+
+		if !waiting {
+			slog.Warn("Waiting for the migration lock", "held_by", holder.String(), "table", lockTable)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("failed to acquire migration lock within %s: held by %s, delete its row from %s if that run is gone", wait, holder, lockTable)
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+func (s *SQLite) lockHolder(ctx context.Context) (lockHolder, error) {
+	var holder lockHolder
+	err := s.conn.QueryRowContext(ctx, "SELECT pid, host, locked_at FROM "+lockTable+" WHERE id = 1").Scan(&holder.pid, &holder.host, &holder.lockedAt)
+	return holder, err
+}
+
+// Unlock runs even after the run was cancelled, so it doesn't take a context.
+func (s *SQLite) Unlock() error {
+	if s.lockOwner == nil {
+		return nil
+	}
+	defer func() { s.lockOwner = nil }()
+	_, err := s.conn.ExecContext(context.Background(), "DELETE FROM "+lockTable+" WHERE id = 1 AND pid = ? AND host = ?", s.lockOwner.pid, s.lockOwner.host)
+	return err
+}
+
+func (s *SQLite) createMigrationsTable(ctx context.Context) error {
 	query := `
 	CREATE TABLE IF NOT EXISTS vagabond_migrations (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		migration_id TEXT NOT NULL UNIQUE,
 		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	)`
-	_, err := s.conn.Exec(query)
+	_, err := s.conn.ExecContext(ctx, query)
 	return err
 }
 
-func (s *SQLite) GetAppliedMigrations() (map[string]bool, error) {
-	rows, err := s.conn.Query("SELECT migration_id FROM vagabond_migrations ORDER BY applied_at ASC")
+func (s *SQLite) GetAppliedMigrations(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT migration_id FROM vagabond_migrations ORDER BY applied_at ASC")
 	if err != nil {
 		return nil, err
 	}
@@ -61,8 +202,8 @@ func (s *SQLite) GetAppliedMigrations() (map[string]bool, error) {
 	return applied, nil
 }
 
-func (s *SQLite) GetAppliedMigrationsList() ([]string, error) {
-	rows, err := s.conn.Query("SELECT migration_id FROM vagabond_migrations ORDER BY applied_at ASC")
+func (s *SQLite) GetAppliedMigrationsList(ctx context.Context) ([]string, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT migration_id FROM vagabond_migrations ORDER BY applied_at ASC")
 	if err != nil {
 		return nil, err
 	}
@@ -79,25 +220,22 @@ func (s *SQLite) GetAppliedMigrationsList() ([]string, error) {
 	return list, nil
 }
 
-func (s *SQLite) ExecuteMigration(filePath string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
-	}
+// ApplyMigration runs the SQL of an up migration and records it under id.
+func (s *SQLite) ApplyMigration(ctx context.Context, id, content string) error {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
 
-	tx, err := s.conn.Begin()
+	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(string(data)); err != nil {
+	if _, err := tx.ExecContext(ctx, content); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to execute migration: %w", err)
 	}
 
-	_, migrationFile := filepath.Split(filePath)
-	migrationID := strings.TrimSuffix(migrationFile, ".sql")
-	_, err = tx.Exec("INSERT INTO vagabond_migrations (migration_id) VALUES (?)", migrationID)
+	_, err = tx.ExecContext(ctx, "INSERT INTO vagabond_migrations (migration_id) VALUES (?)", id)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to record migration: %w", err)
@@ -106,24 +244,23 @@ func (s *SQLite) ExecuteMigration(filePath string) error {
 	return tx.Commit()
 }
 
-func (s *SQLite) RollbackMigration(filePath string, name string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
-	}
+// RevertMigration runs the SQL of a down migration and removes the record
+// of the up migration id.
+func (s *SQLite) RevertMigration(ctx context.Context, id, content string) error {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
 
-	tx, err := s.conn.Begin()
+	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(string(data)); err != nil {
+	if _, err := tx.ExecContext(ctx, content); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to execute migration: %w", err)
 	}
 
-	migrationID := strings.TrimSuffix(name, ".sql")
-	_, err = tx.Exec("DELETE from vagabond_migrations WHERE migration_id = ?", migrationID)
+	_, err = tx.ExecContext(ctx, "DELETE from vagabond_migrations WHERE migration_id = ?", id)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to delete migration record: %w", err)
@@ -132,16 +269,62 @@ func (s *SQLite) RollbackMigration(filePath string, name string) error {
 	return tx.Commit()
 }
 
-func (s *SQLite) DumpSchema() (string, error) {
+func (s *SQLite) Query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.conn.QueryContext(ctx, query, args...)
+}
+
+func (s *SQLite) RecordMigration(ctx context.Context, id string) error {
+	_, err := s.conn.ExecContext(ctx, "INSERT INTO vagabond_migrations (migration_id) VALUES (?)", id)
+	return err
+}
+
+// Exec runs SQL that isn't a migration, such as a hook, outside of a
+// migration transaction and without recording anything.
+func (s *SQLite) Exec(ctx context.Context, query string) error {
+	ctx, cancel := s.withStatementTimeout(ctx)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, query)
+	return err
+}
+
+// withStatementTimeout bounds a migration with the statement timeout. sqlite
+// has no server side setting, so the timeout interrupts the whole migration.
+func (s *SQLite) withStatementTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.statementTimeout)
+}
+
+func (s *SQLite) DumpSchema(ctx context.Context) (string, error) {
 	var schema strings.Builder
 
 	schema.WriteString("-- This file has been automatically generated based on the current database state.\n")
 	schema.WriteString("-- Manual modification of this file is not recommended. Use database migrations for schema changes.\n\n")
 	schema.WriteString("PRAGMA foreign_keys = OFF;\n\n")
 
-	rows, err := s.conn.Query(`
+	pragmas, err := s.schemaPragmas(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch pragmas: %w", err)
+	}
+	for _, pragma := range pragmas {
+		schema.WriteString(pragma + ";\n")
+	}
+	if len(pragmas) > 0 {
+		schema.WriteString("\n")
+	}
+
+	// objects are created in dependency order: tables, then indexes, then
+	// views and triggers, which may use each other, in the order they were
+	// created
+	rows, err := s.conn.QueryContext(ctx, `
 		SELECT sql FROM sqlite_master
-		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND sql IS NOT NULL
+		WHERE type IN ('table', 'index', 'view', 'trigger') AND name NOT LIKE 'sqlite_%' AND name != '`+lockTable+`' AND sql IS NOT NULL
+		ORDER BY
+			CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 ELSE 2 END,
+			CASE WHEN type IN ('table', 'index') THEN name END,
+			rowid
 	`)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch schema: %w", err)
@@ -158,7 +341,7 @@ func (s *SQLite) DumpSchema() (string, error) {
 
 	schema.WriteString("PRAGMA foreign_keys = ON;\n\n")
 
-	migrationRows, err := s.conn.Query(`SELECT id, migration_id FROM vagabond_migrations ORDER BY id`)
+	migrationRows, err := s.conn.QueryContext(ctx, `SELECT id, migration_id FROM vagabond_migrations ORDER BY id`)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch applied migrations: %w", err)
 	}
@@ -182,3 +365,275 @@ func (s *SQLite) DumpSchema() (string, error) {
 
 	return schema.String(), nil
 }
+
+// schemaPragmas returns the persistent pragmas that change how the schema
+// behaves, skipping the ones left at their default value.
+func (s *SQLite) schemaPragmas(ctx context.Context) ([]string, error) {
+	var pragmas []string
+
+	var autoVacuum int
+	if err := s.conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+		return nil, err
+	}
+	if autoVacuum != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA auto_vacuum = %d", autoVacuum))
+	}
+
+	var journalMode string
+	if err := s.conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(journalMode, "wal") {
+		pragmas = append(pragmas, "PRAGMA journal_mode = WAL")
+	}
+
+	var userVersion, applicationID int
+	if err := s.conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&userVersion); err != nil {
+		return nil, err
+	}
+	if userVersion != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA user_version = %d", userVersion))
+	}
+	if err := s.conn.QueryRowContext(ctx, "PRAGMA application_id").Scan(&applicationID); err != nil {
+		return nil, err
+	}
+	if applicationID != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA application_id = %d", applicationID))
+	}
+
+	return pragmas, nil
+}
+
+func (s *SQLite) InspectSchema(ctx context.Context) (*Schema, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT IN (?, ?)
+		ORDER BY name
+	`, migrationsTable, lockTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tables: %w", err)
+	}
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+
+	schema := &Schema{}
+	for _, name := range names {
+		table, err := s.inspectTable(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect table %s: %w", name, err)
+		}
+		schema.Tables = append(schema.Tables, table)
+	}
+	return schema, nil
+}
+
+func (s *SQLite) InspectDDL(ctx context.Context, ddl string) (*Schema, error) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scratch database: %w", err)
+	}
+	defer conn.Close()
+
+	// every connection to :memory: is a separate database
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.ExecContext(ctx, ddl); err != nil {
+		return nil, fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	scratch := &SQLite{conn: conn}
+	return scratch.InspectSchema(ctx)
+}
+
+func (s *SQLite) inspectTable(ctx context.Context, name string) (Table, error) {
+	table := Table{Name: name}
+
+	err := s.conn.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&table.Definition)
+	if err != nil {
+		return table, err
+	}
+	table.Checks = sqliteChecks(table.Definition)
+
+	rows, err := s.conn.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%q)", name))
+	if err != nil {
+		return table, err
+	}
+	pk := map[int]string{}
+	for rows.Next() {
+		var cid, notNull, pkPos int
+		var colName, colType string
+		var defaultVal *string
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &defaultVal, &pkPos); err != nil {
+			rows.Close()
+			return table, err
+		}
+		table.Columns = append(table.Columns, Column{
+			Name:     colName,
+			Type:     colType,
+			Nullable: notNull == 0 && pkPos == 0,
+			Default:  defaultVal,
+		})
+		if pkPos > 0 {
+			pk[pkPos] = colName
+		}
+	}
+	rows.Close()
+	for i := 1; i <= len(pk); i++ {
+		table.PrimaryKey = append(table.PrimaryKey, pk[i])
+	}
+
+	rows, err = s.conn.QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_list(%q)", name))
+	if err != nil {
+		return table, err
+	}
+	fks := map[int]*ForeignKey{}
+	var fkOrder []int
+	for rows.Next() {
+		var id, seq int
+		var refTable, from, onUpdate, onDelete, match string
+		var to sql.NullString
+		if err := rows.Scan(&id, &seq, &refTable, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			rows.Close()
+			return table, err
+		}
+		fk, ok := fks[id]
+		if !ok {
+			fk = &ForeignKey{RefTable: refTable}
+			fks[id] = fk
+			fkOrder = append(fkOrder, id)
+		}
+		fk.Columns = append(fk.Columns, from)
+		if to.Valid {
+			fk.RefColumns = append(fk.RefColumns, to.String)
+		}
+	}
+	rows.Close()
+	// sqlite does not expose constraint names, so order them by their columns
+	for _, id := range fkOrder {
+		table.ForeignKeys = append(table.ForeignKeys, *fks[id])
+	}
+	sort.Slice(table.ForeignKeys, func(i, j int) bool {
+		return strings.Join(table.ForeignKeys[i].Columns, ",") < strings.Join(table.ForeignKeys[j].Columns, ",")
+	})
+
+	rows, err = s.conn.QueryContext(ctx, fmt.Sprintf("PRAGMA index_list(%q)", name))
+	if err != nil {
+		return table, err
+	}
+	type indexEntry struct {
+		name   string
+		unique bool
+		origin string
+	}
+	var entries []indexEntry
+	for rows.Next() {
+		var seq, unique, partial int
+		var idxName, origin string
+		if err := rows.Scan(&seq, &idxName, &unique, &origin, &partial); err != nil {
+			rows.Close()
+			return table, err
+		}
+		entries = append(entries, indexEntry{idxName, unique == 1, origin})
+	}
+	rows.Close()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	for _, entry := range entries {
+		if entry.origin == "pk" {
+			continue
+		}
+		columns, err := s.indexColumns(ctx, entry.name)
+		if err != nil {
+			return table, err
+		}
+		if entry.origin == "u" {
+			table.UniqueKeys = append(table.UniqueKeys, UniqueKey{Columns: columns})
+			continue
+		}
+
+		var def string
+		err = s.conn.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?", entry.name).Scan(&def)
+		if err != nil {
+			return table, err
+		}
+		table.Indexes = append(table.Indexes, Index{
+			Name:       entry.name,
+			Columns:    columns,
+			Unique:     entry.unique,
+			Definition: def,
+		})
+	}
+	return table, nil
+}
+
+var (
+	sqliteMasked = regexp.MustCompile(`'(?:[^']|'')*'|--[^\n]*|/\*[\s\S]*?\*/`)
+	sqliteCheck  = regexp.MustCompile(`(?i)(?:\bCONSTRAINT\s+("(?:[^"]|"")+"|` + "`[^`]+`" + `|\[[^\]]+\]|\w+)\s+)?\bCHECK\s*\(`)
+)
+
+// sqliteChecks finds the CHECK constraints of a CREATE TABLE statement,
+// sqlite has no pragma listing them.
+func sqliteChecks(definition string) []Check {
+	// string literals and comments are blanked out so that their content
+	// doesn't match, keeping the offsets into definition
+	masked := sqliteMasked.ReplaceAllStringFunc(definition, func(text string) string {
+		return strings.Repeat(" ", len(text))
+	})
+
+	var checks []Check
+	for _, match := range sqliteCheck.FindAllStringSubmatchIndex(masked, -1) {
+		expr, _, ok := parenthesized(definition[match[1]-1:])
+		if !ok {
+			continue
+		}
+		check := Check{Expression: strings.TrimSpace(expr)}
+		if match[2] >= 0 {
+			check.Name = unquoteSQLite(definition[match[2]:match[3]])
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func unquoteSQLite(name string) string {
+	if len(name) >= 2 {
+		switch name[0] {
+		case '"':
+			return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		case '`', '[':
+			return name[1 : len(name)-1]
+		}
+	}
+	return name
+}
+
+func (s *SQLite) indexColumns(ctx context.Context, index string) ([]string, error) {
+	rows, err := s.conn.QueryContext(ctx, fmt.Sprintf("PRAGMA index_info(%q)", index))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var seqno, cid int
+		var name sql.NullString
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return nil, err
+		}
+		if !name.Valid {
+			name.String = "<expression>"
+		}
+		columns = append(columns, name.String)
+	}
+	return columns, nil
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteChecks(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		want       []Check
+	}{
+		{
+			name:       "column and table checks",
+			definition: "CREATE TABLE t (price REAL CHECK (price > 0), qty INT, CHECK(qty BETWEEN 1 AND (10)))",
+			want:       []Check{{Expression: "price > 0"}, {Expression: "qty BETWEEN 1 AND (10)"}},
+		},
+		{
+			name:       "named checks",
+			definition: `CREATE TABLE t (a INT CONSTRAINT positive CHECK (a > 0), CONSTRAINT "odd name" CHECK (a < 10), CONSTRAINT [b] CHECK (a != 5))`,
+			want:       []Check{{Name: "positive", Expression: "a > 0"}, {Name: "odd name", Expression: "a < 10"}, {Name: "b", Expression: "a != 5"}},
+		},
+		{
+			name:       "strings and comments are not checks",
+			definition: "CREATE TABLE t (\n  a TEXT DEFAULT 'check (x)', -- check (y)\n  b TEXT CHECK (b != ')')\n)",
+			want:       []Check{{Expression: "b != ')'"}},
+		},
+		{
+			name:       "no checks",
+			definition: "CREATE TABLE checks (id INTEGER PRIMARY KEY AUTOINCREMENT)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sqliteChecks(tt.definition); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sqliteChecks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteDumpSchemaOrder(t *testing.T) {
+	ctx := context.Background()
+	source := openSQLite(t, `
+CREATE TABLE zebras (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE animals (id INTEGER PRIMARY KEY, zebra_id INTEGER REFERENCES zebras (id));
+CREATE VIEW z_names AS SELECT name FROM zebras;
+CREATE VIEW a_names AS SELECT name FROM z_names;
+CREATE TABLE log (name TEXT);
+CREATE TRIGGER a_insert INSTEAD OF INSERT ON a_names BEGIN INSERT INTO log VALUES (NEW.name); END;
+`)
+
+	dump, err := source.DumpSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, name := range []string{"TABLE animals", "TABLE log", "TABLE zebras", "VIEW z_names", "VIEW a_names", "TRIGGER a_insert"} {
+		order = append(order, "CREATE "+name)
+	}
+	rest := dump
+	for _, stmt := range order {
+		i := strings.Index(rest, stmt)
+		if i < 0 {
+			t.Fatalf("dump is missing %q after the previous objects:\n%s", stmt, dump)
+		}
+		rest = rest[i:]
+	}
+
+	// the dump replays into an empty database, where the view works
+	replayed := openSQLite(t, dump)
+	if _, err := replayed.conn.ExecContext(ctx, "INSERT INTO a_names VALUES ('zed')"); err != nil {
+		t.Errorf("using the replayed view: %v", err)
+	}
+}
+
+func openSQLite(t *testing.T, ddl string) *SQLite {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetMaxOpenConns(1)
+	if _, err := conn.ExecContext(ctx, ddl); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSQLite(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSQLiteLock(t *testing.T) {
+	ctx := context.Background()
+	host, _ := os.Hostname()
+
+	// a process that exited, its pid is free
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run a process: %v", err)
+	}
+	gone := cmd.Process.Pid
+
+	tests := []struct {
+		name    string
+		holder  *lockHolder // the row left in the lock table, if any
+		wantErr string
+	}{
+		{name: "free"},
+		{name: "held by a process that is gone", holder: &lockHolder{pid: gone, host: host}},
+		{name: "held by this process", holder: &lockHolder{pid: os.Getpid(), host: host}, wantErr: fmt.Sprintf("held by pid %d on %s since", os.Getpid(), host)},
+		{name: "held on another host", holder: &lockHolder{pid: gone, host: "elsewhere"}, wantErr: fmt.Sprintf("held by pid %d on elsewhere since", gone)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SQLite{busyTimeout: 300 * time.Millisecond}
+			if err := s.Connect(ctx, filepath.Join(t.TempDir(), "app.db")); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if tt.holder != nil {
+				// a first lock creates the table, the row is then swapped
+				if err := s.Lock(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := s.conn.ExecContext(ctx, "UPDATE "+lockTable+" SET pid = ?, host = ?", tt.holder.pid, tt.holder.host); err != nil {
+					t.Fatal(err)
+				}
+				s.lockOwner = nil
+			}
+
+			err := s.Lock(ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Lock() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lock() error = %v", err)
+			}
+			if err := s.Unlock(); err != nil {
+				t.Fatalf("Unlock() error = %v", err)
+			}
+			var rows int
+			if err := s.conn.QueryRowContext(ctx, "SELECT count(*) FROM "+lockTable).Scan(&rows); err != nil || rows != 0 {
+				t.Errorf("lock rows after Unlock() = %d (%v), want 0", rows, err)
+			}
+		})
+	}
+}
+
+func TestSQLiteLockWaits(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "app.db")
+	first, second := &SQLite{}, &SQLite{busyTimeout: 5 * time.Second}
+	for _, s := range []*SQLite{first, second} {
+		if err := s.Connect(ctx, path); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+	}
+
+	if err := first.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(3 * lockPoll)
+		first.Unlock()
+	}()
+	start := time.Now()
+	if err := second.Lock(ctx); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if waited := time.Since(start); waited < 2*lockPoll {
+		t.Errorf("Lock() returned after %s, before the first lock was released", waited)
+	}
+	second.Unlock()
+}
@@ -0,0 +1,162 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+const (
+	Succeeded = "succeeded"
+	Pending   = "pending"
+	UpToDate  = "up to date"
+	Failed    = "failed"
+	Skipped   = "skipped"
+)
+
+type Options struct {
+	Parallel        int
+	ContinueOnError bool
+	DryRun          bool // report changes as pending instead of applied
+}
+
+type Target struct {
+	Name   string
+	Config db.Config
+}
+
+type Result struct {
+	Target  string
+	Status  string
+	Changes int
+	Err     error
+}
+
+// Task runs against a single target and reports how many migrations it
+// changed, zero meaning the target was already up to date.
+// The logger carries the target's name as the database attribute.
+type Task func(ctx context.Context, driver db.Driver, target Target, logger *slog.Logger) (int, error)
+
+func Run(ctx context.Context, targets []Target, opts Options, task Task) []Result {
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]Result, len(targets))
+	for i, target := range targets {
+		results[i] = Result{Target: target.Name, Status: Skipped}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stopped bool
+	)
+	sem := make(chan struct{}, parallel)
+	for i, target := range targets {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := stopped || ctx.Err() != nil
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			logger := slog.Default().With("database", target.Name)
+			result := runTarget(ctx, target, task, opts.DryRun, logger)
+			if result.Err != nil {
+				logger.Error("Target failed", "error", result.Err)
+			} else {
+				logger.Info("Target finished", "status", result.Status, "migrations", result.Changes)
+			}
+
+			mu.Lock()
+			results[i] = result
+			if result.Err != nil && !opts.ContinueOnError {
+				stopped = true
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func runTarget(ctx context.Context, target Target, task Task, dryRun bool, logger *slog.Logger) Result {
+	result := Result{Target: target.Name}
+
+	driver, err := db.New(ctx, target.Config)
+	if err != nil {
+		result.Status, result.Err = Failed, err
+		return result
+	}
+	defer driver.Close()
+
+	result.Changes, err = task(ctx, driver, target, logger)
+	switch {
+	case err != nil:
+		result.Status, result.Err = Failed, err
+	case result.Changes == 0:
+		result.Status = UpToDate
+	case dryRun:
+		result.Status = Pending
+	default:
+		result.Status = Succeeded
+	}
+	return result
+}
+
+func describe(r Result) string {
+	switch r.Status {
+	case Succeeded, Pending:
+		return fmt.Sprintf("%s (%d migration(s))", r.Status, r.Changes)
+	case Failed:
+		return fmt.Sprintf("%s: %v", r.Status, r.Err)
+	default:
+		return r.Status
+	}
+}
+
+func Report(label string, results []Result) error {
+	width := len(label)
+	for _, r := range results {
+		width = max(width, len(r.Target))
+	}
+
+	counts := map[string]int{}
+	fmt.Printf("\n%-*s  %s\n", width, label, "STATUS")
+	for _, r := range results {
+		counts[r.Status]++
+		fmt.Printf("%-*s  %s\n", width, r.Target, describe(r))
+	}
+	fmt.Printf("\n%d succeeded, %d pending, %d up to date, %d failed, %d skipped\n",
+		counts[Succeeded], counts[Pending], counts[UpToDate], counts[Failed], counts[Skipped])
+
+	return Check(results)
+}
+
+// Check returns an error when any target failed.
+func Check(results []Result) error {
+	failed := 0
+	for _, r := range results {
+		if r.Status == Failed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d target(s) failed", failed, len(results))
+	}
+	return nil
+}
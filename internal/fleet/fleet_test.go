@@ -0,0 +1,144 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+func TestRun(t *testing.T) {
+	errBoom := errors.New("boom")
+	// the task of each target returns the changes and error given by its name
+	outcomes := map[string]struct {
+		changes int
+		err     error
+	}{
+		"applied":  {changes: 2},
+		"current":  {},
+		"failing":  {err: errBoom},
+		"applied2": {changes: 1},
+	}
+	task := func(ctx context.Context, driver db.Driver, target Target, logger *slog.Logger) (int, error) {
+		o := outcomes[target.Name]
+		return o.changes, o.err
+	}
+
+	tests := []struct {
+		name    string
+		targets []string
+		opts    Options
+		want    []Result
+		wantErr string
+	}{
+		{
+			name:    "all succeed",
+			targets: []string{"applied", "current"},
+			opts:    Options{Parallel: 2},
+			want: []Result{
+				{Target: "applied", Status: Succeeded, Changes: 2},
+				{Target: "current", Status: UpToDate},
+			},
+		},
+		{
+			name:    "dry run",
+			targets: []string{"applied", "current"},
+			opts:    Options{DryRun: true},
+			want: []Result{
+				{Target: "applied", Status: Pending, Changes: 2},
+				{Target: "current", Status: UpToDate},
+			},
+		},
+		{
+			name:    "a failure stops the targets after it",
+			targets: []string{"applied", "failing", "applied2"},
+			opts:    Options{Parallel: 1},
+			want: []Result{
+				{Target: "applied", Status: Succeeded, Changes: 2},
+				{Target: "failing", Status: Failed, Err: errBoom},
+				{Target: "applied2", Status: Skipped},
+			},
+			wantErr: "1 of 3 target(s) failed",
+		},
+		{
+			name:    "continue on error",
+			targets: []string{"failing", "applied2"},
+			opts:    Options{Parallel: 1, ContinueOnError: true},
+			want: []Result{
+				{Target: "failing", Status: Failed, Err: errBoom},
+				{Target: "applied2", Status: Succeeded, Changes: 1},
+			},
+			wantErr: "1 of 2 target(s) failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			targets := make([]Target, len(tt.targets))
+			for i, name := range tt.targets {
+				targets[i] = Target{Name: name, Config: db.Config{Type: "sqlite", DSN: filepath.Join(dir, name+".db")}}
+			}
+
+			got := Run(context.Background(), targets, tt.opts, task)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run() = %+v, want %+v", got, tt.want)
+			}
+			err := Check(got)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Check() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunConnectionFailure(t *testing.T) {
+	targets := []Target{{Name: "unknown", Config: db.Config{Type: "oracle"}}}
+	task := func(ctx context.Context, driver db.Driver, target Target, logger *slog.Logger) (int, error) {
+		t.Error("the task ran without a connection")
+		return 0, nil
+	}
+
+	results := Run(context.Background(), targets, Options{}, task)
+	if len(results) != 1 || results[0].Status != Failed || results[0].Err == nil {
+		t.Fatalf("Run() = %+v, want a failed target", results)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	targets := []Target{{Name: "a"}, {Name: "b"}}
+	task := func(ctx context.Context, driver db.Driver, target Target, logger *slog.Logger) (int, error) {
+		return 0, nil
+	}
+
+	for _, r := range Run(ctx, targets, Options{}, task) {
+		if r.Status != Skipped {
+			t.Errorf("%s: status = %q, want %q", r.Target, r.Status, Skipped)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		result Result
+		want   string
+	}{
+		{Result{Status: Succeeded, Changes: 3}, "succeeded (3 migration(s))"},
+		{Result{Status: Pending, Changes: 1}, "pending (1 migration(s))"},
+		{Result{Status: UpToDate}, "up to date"},
+		{Result{Status: Failed, Err: errors.New("boom")}, "failed: boom"},
+		{Result{Status: Skipped}, "skipped"},
+	}
+
+	for _, tt := range tests {
+		if got := describe(tt.result); got != tt.want {
+			t.Errorf("describe(%+v) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package interop
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/sqlscript"
+)
+
+// Exported is a vagabond migration written by Export.
+type Exported struct {
+	ID      string // the vagabond migration ID
+	Version string // the version in the target layout
+	Name    string
+	Script  string // the target's up file, as Flyway records it
+	Up      string // what the target runs, for checksums
+}
+
+var dollarQuote = regexp.MustCompile(`\$\w*\$`)
+
+// Export writes the vagabond migrations into dir in the layout of tool.
+// Versions are kept when their numeric order is vagabond's order, otherwise
+// the migrations are numbered from 1 in vagabond's order.
+func Export(dir, tool string) ([]Exported, error) {
+	write, err := writer(tool)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := migrations.UpFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migrations to export")
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s is not empty, export into a new directory", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	list, err := readVagabond(files)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]Exported, len(list))
+	for i, m := range list {
+		exported[i] = Exported{ID: m.Version + "_" + m.Name + "_up", Version: m.Version, Name: m.Name, Up: m.Up}
+	}
+	if !increasing(list) {
+		for i := range list {
+			list[i].Version = fmt.Sprintf("%04d", i+1)
+		}
+	}
+
+	for i, m := range list {
+		script, err := write(dir, m)
+		if err != nil {
+			return nil, err
+		}
+		exported[i].Version, exported[i].Script = m.Version, script
+	}
+	return exported, nil
+}
+
+// readVagabond reads the migrations with their file name comment and
+// vagabond annotations removed, the annotations being turned into the
+// fields the target layouts understand.
+func readVagabond(files []string) ([]Migration, error) {
+	var list []Migration
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".sql")
+		version := migrations.Version(id)
+		name := strings.TrimSuffix(strings.TrimPrefix(id, version+"_"), "_up")
+
+		up, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %s: %w", file, err)
+		}
+		downFile := strings.TrimSuffix(file, "_up.sql") + "_down.sql"
+		down, err := os.ReadFile(downFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading file %s: %w", downFile, err)
+		}
+
+		m := Migration{
+			Version:       version,
+			Name:          name,
+			Up:            strip(string(up), filepath.Base(file)),
+			Down:          strip(string(down), filepath.Base(downFile)),
+			HasDown:       err == nil,
+			NoTransaction: sqlscript.HasAnnotation(string(up), "no-transaction"),
+		}
+		if migrations.IsIrreversible(string(up)) || migrations.IsIrreversible(string(down)) {
+			m.Down, m.HasDown = "", false
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+func strip(content, file string) string {
+	content = strings.TrimPrefix(content, "-- "+file+"\n")
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "-- vagabond:") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+func increasing(list []Migration) bool {
+	var last uint64
+	for i, m := range list {
+		n, err := strconv.ParseUint(m.Version, 10, 64)
+		if err != nil || (i > 0 && n <= last) {
+			return false
+		}
+		last = n
+	}
+	return true
+}
+
+// writer returns the function writing one migration in the layout of tool,
+// which returns the name of the file holding the up migration.
+func writer(tool string) (func(dir string, m Migration) (string, error), error) {
+	switch tool {
+	case "goose":
+		return writeGoose, nil
+	case "migrate":
+		return writeMigrate, nil
+	case "dbmate":
+		return writeDbmate, nil
+	case "flyway":
+		return writeFlyway, nil
+	default:
+		return nil, fmt.Errorf("unsupported tool %q: use %s", tool, strings.Join(Tools(), ", "))
+	}
+}
+
+func writeGoose(dir string, m Migration) (string, error) {
+	var b strings.Builder
+	if m.NoTransaction {
+		b.WriteString("-- +goose NO TRANSACTION\n")
+	}
+	b.WriteString("-- +goose Up\n")
+	b.WriteString(gooseStatements(m.Up))
+	if m.HasDown {
+		b.WriteString("\n-- +goose Down\n")
+		b.WriteString(gooseStatements(m.Down))
+	}
+	name := m.Version + "_" + m.Name + ".sql"
+	return name, writeFile(dir, name, b.String())
+}
+
+// gooseStatements keeps goose from splitting dollar-quoted bodies on their
+// semicolons.
+func gooseStatements(sql string) string {
+	if !dollarQuote.MatchString(sql) {
+		return sql
+	}
+	return "-- +goose StatementBegin\n" + sql + "-- +goose StatementEnd\n"
+}
+
+func writeMigrate(dir string, m Migration) (string, error) {
+	name := m.Version + "_" + m.Name + ".up.sql"
+	if err := writeFile(dir, name, m.Up); err != nil {
+		return "", err
+	}
+	if m.HasDown {
+		return name, writeFile(dir, m.Version+"_"+m.Name+".down.sql", m.Down)
+	}
+	return name, nil
+}
+
+func writeDbmate(dir string, m Migration) (string, error) {
+	options := ""
+	if m.NoTransaction {
+		options = " transaction:false"
+	}
+	content := "-- migrate:up" + options + "\n" + m.Up
+	if m.HasDown {
+		content += "\n-- migrate:down" + options + "\n" + m.Down
+	}
+	name := m.Version + "_" + m.Name + ".sql"
+	return name, writeFile(dir, name, content)
+}
+
+// writeFlyway writes V and, for Flyway Teams, U files. A .conf file next to
+// the migration turns off its transaction.
+func writeFlyway(dir string, m Migration) (string, error) {
+	m.Version = trimZeros(m.Version)
+	name := "V" + m.Version + "__" + m.Name + ".sql"
+	if err := writeFile(dir, name, m.Up); err != nil {
+		return "", err
+	}
+	if m.HasDown {
+		if err := writeFile(dir, "U"+m.Version+"__"+m.Name+".sql", m.Down); err != nil {
+			return "", err
+		}
+	}
+	if m.NoTransaction {
+		if err := writeFile(dir, name+".conf", "executeInTransaction=false\n"); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+func writeFile(dir, name, content string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("error writing file %s: %w", name, err)
+	}
+	return nil
+}
+
+// HistoryStatements returns the SQL that creates the history table of tool
+// and marks the exported migrations in applied, the IDs recorded in
+// vagabond_migrations, as applied, so the tool takes over where vagabond
+// left off.
+func HistoryStatements(tool, dialect, table string, exported []Exported, applied []string) (string, error) {
+	if table == "" {
+		table = HistoryTable(tool)
+	}
+	if !tableName.MatchString(table) {
+		return "", fmt.Errorf("invalid history table name %q", table)
+	}
+
+	done := map[string]bool{}
+	for _, id := range applied {
+		done[id] = true
+	}
+	var list []Exported
+	for _, m := range exported {
+		if done[m.ID] {
+			list = append(list, m)
+		}
+	}
+
+	var b strings.Builder
+	switch tool {
+	case "goose":
+		id := "serial PRIMARY KEY"
+		if dialect == "sqlite" {
+			id = "INTEGER PRIMARY KEY AUTOINCREMENT"
+		}
+		fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n  id %s,\n  version_id bigint NOT NULL,\n  is_applied boolean NOT NULL,\n  tstamp timestamp DEFAULT CURRENT_TIMESTAMP\n);\n", table, id)
+		fmt.Fprintf(&b, "INSERT INTO %s (version_id, is_applied) VALUES (0, TRUE);\n", table)
+		for _, m := range list {
+			fmt.Fprintf(&b, "INSERT INTO %s (version_id, is_applied) VALUES (%s, TRUE);\n", table, trimZeros(m.Version))
+		}
+	case "migrate":
+		// golang-migrate only keeps the latest version, which stands for
+		// everything before it
+		for i, m := range exported {
+			if i >= len(list) {
+				break
+			}
+			if list[i].ID != m.ID {
+				return "", fmt.Errorf("%s is not applied but later migrations are, golang-migrate cannot record that", m.ID)
+			}
+		}
+		fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n  version bigint NOT NULL PRIMARY KEY,\n  dirty boolean NOT NULL\n);\n", table)
+		if len(list) > 0 {
+			fmt.Fprintf(&b, "INSERT INTO %s (version, dirty) VALUES (%s, FALSE);\n", table, trimZeros(list[len(list)-1].Version))
+		}
+	case "dbmate":
+		fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n  version varchar(128) PRIMARY KEY\n);\n", table)
+		for _, m := range list {
+			fmt.Fprintf(&b, "INSERT INTO %s (version) VALUES ('%s');\n", table, m.Version)
+		}
+	case "flyway":
+		fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n  installed_rank integer NOT NULL PRIMARY KEY,\n  version varchar(50),\n  description varchar(200) NOT NULL,\n  type varchar(20) NOT NULL,\n  script varchar(1000) NOT NULL,\n  checksum integer,\n  installed_by varchar(100) NOT NULL,\n  installed_on timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,\n  execution_time integer NOT NULL,\n  success boolean NOT NULL\n);\n", table)
+		for i, m := range list {
+			fmt.Fprintf(&b, "INSERT INTO %s (installed_rank, version, description, type, script, checksum, installed_by, execution_time, success) VALUES (%d, '%s', '%s', 'SQL', '%s', %d, 'vagabond', 0, TRUE);\n",
+				table, i+1, trimZeros(m.Version), strings.ReplaceAll(m.Name, "_", " "), m.Script, flywayChecksum(m.Up))
+		}
+	default:
+		return "", fmt.Errorf("unsupported tool %q: use %s", tool, strings.Join(Tools(), ", "))
+	}
+	return b.String(), nil
+}
+
+func trimZeros(version string) string {
+	if trimmed := strings.TrimLeft(version, "0"); trimmed != "" {
+		return trimmed
+	}
+	return "0"
+}
+
+// flywayChecksum is the CRC32 Flyway computes over the lines of a script,
+// without their line breaks.
+func flywayChecksum(content string) int32 {
+	crc := crc32.NewIEEE()
+	content = strings.TrimPrefix(content, "\uFEFF")
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		crc.Write([]byte(strings.TrimSuffix(line, "\r")))
+	}
+	return int32(crc.Sum32())
+}
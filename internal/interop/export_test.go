@@ -0,0 +1,273 @@
+package interop
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var vagabondMigrations = map[string]string{
+	"0001_add_users_up.sql":     "-- 0001_add_users_up.sql\nCREATE TABLE users (id int);\n",
+	"0001_add_users_down.sql":   "-- 0001_add_users_down.sql\nDROP TABLE users;\n",
+	"0002_users_email_up.sql":   "-- vagabond:no-transaction\nCREATE INDEX CONCURRENTLY users_email ON users (email);\n",
+	"0002_users_email_down.sql": "-- vagabond:no-transaction\nDROP INDEX users_email;\n",
+	"0010_backfill_up.sql":      "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\n",
+	"0010_backfill_down.sql":    "-- vagabond:irreversible\n",
+}
+
+// TestExport writes the migrations in each layout and reads them back with
+// the importer of the same tool.
+func TestExport(t *testing.T) {
+	users := Migration{Name: "add_users", Up: "CREATE TABLE users (id int);\n", Down: "DROP TABLE users;\n", HasDown: true}
+	email := Migration{Name: "users_email", Up: "CREATE INDEX CONCURRENTLY users_email ON users (email);\n", Down: "DROP INDEX users_email;\n", HasDown: true, NoTransaction: true}
+	backfill := Migration{Name: "backfill", Up: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\n"}
+	// golang-migrate files have no transaction setting, and Flyway keeps it
+	// in a .conf file the importer doesn't look at
+	versioned := func(noTransaction bool, versions ...string) []Migration {
+		list := []Migration{users, email, backfill}
+		for i := range list {
+			list[i].Version = versions[i]
+		}
+		list[1].NoTransaction = noTransaction
+		return list
+	}
+
+	tests := []struct {
+		tool   string
+		want   []Migration
+		script string
+		// file holds a line the importer doesn't read back
+		file, line string
+	}{
+		{"goose", versioned(true, "0001", "0002", "0010"), "0001_add_users.sql", "0010_backfill.sql", "-- +goose StatementBegin"},
+		{"migrate", versioned(false, "0001", "0002", "0010"), "0001_add_users.up.sql", "0002_users_email.down.sql", "DROP INDEX users_email;"},
+		{"dbmate", versioned(true, "0001", "0002", "0010"), "0001_add_users.sql", "0002_users_email.sql", "-- migrate:down transaction:false"},
+		{"flyway", versioned(false, "1", "2", "10"), "V1__add_users.sql", "V2__users_email.sql.conf", "executeInTransaction=false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			writeFiles(t, "migrations", vagabondMigrations)
+
+			exported, err := Export("out", tt.tool)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if len(exported) != 3 || exported[0].ID != "0001_add_users_up" || exported[0].Script != tt.script {
+				t.Errorf("Export() = %+v, want 0001_add_users_up first, written to %s", exported, tt.script)
+			}
+
+			data, err := os.ReadFile(filepath.Join("out", tt.file))
+			if err != nil || !strings.Contains(string(data), tt.line) {
+				t.Errorf("%s = %q, %v, want a %q line", tt.file, data, err, tt.line)
+			}
+
+			read, err := reader(tt.tool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := read("out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// goose and dbmate keep the blank line between the sections
+			for i := range got {
+				got[i].Up = strings.TrimSpace(got[i].Up) + "\n"
+				got[i].Down = strings.TrimSpace(got[i].Down) + "\n"
+				if !got[i].HasDown {
+					got[i].Down = ""
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read back %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportRenumbers(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// sequential versions sort after timestamped ones in vagabond, but not
+	// numerically
+	writeFiles(t, "migrations", map[string]string{
+		"20240117093012_add_users_up.sql": "CREATE TABLE users (id int);",
+		"0001_add_posts_up.sql":           "CREATE TABLE posts (id int);",
+	})
+
+	exported, err := Export("out", "migrate")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	var got []string
+	for _, m := range exported {
+		got = append(got, m.ID+" -> "+m.Script)
+	}
+	want := []string{"20240117093012_add_users_up -> 0001_add_users.up.sql", "0001_add_posts_up -> 0002_add_posts.up.sql"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Export() = %v, want %v", got, want)
+	}
+}
+
+// TestHistoryStatements runs the statements on SQLite and reads the history
+// back the way an import would.
+func TestHistoryStatements(t *testing.T) {
+	exported := []Exported{
+		{ID: "0001_add_users_up", Version: "0001", Name: "add_users", Script: "V1__add_users.sql", Up: "CREATE TABLE users (id int);\n"},
+		{ID: "0002_add_posts_up", Version: "0002", Name: "add_posts", Script: "V2__add_posts.sql", Up: "CREATE TABLE posts (id int);\n"},
+		{ID: "0003_add_tags_up", Version: "0003", Name: "add_tags", Script: "V3__add_tags.sql", Up: "CREATE TABLE tags (id int);\n"},
+	}
+	numbered := []Migration{{Version: "0001"}, {Version: "0002"}, {Version: "0003"}}
+	dotted := []Migration{{Version: "1"}, {Version: "2"}, {Version: "3"}}
+
+	tests := []struct {
+		name    string
+		tool    string
+		table   string
+		applied []string
+		list    []Migration
+		want    map[string]bool
+		wantErr string
+	}{
+		{
+			name:    "goose",
+			tool:    "goose",
+			applied: []string{"0001_add_users_up", "0003_add_tags_up"},
+			list:    numbered,
+			want:    map[string]bool{"0001": true, "0003": true},
+		},
+		{
+			name:    "migrate",
+			tool:    "migrate",
+			applied: []string{"0001_add_users_up", "0002_add_posts_up"},
+			list:    numbered,
+			want:    map[string]bool{"0001": true, "0002": true},
+		},
+		{
+			name:    "migrate with nothing applied",
+			tool:    "migrate",
+			applied: nil,
+			list:    numbered,
+			want:    map[string]bool{},
+		},
+		{
+			name:    "migrate with a gap",
+			tool:    "migrate",
+			applied: []string{"0001_add_users_up", "0003_add_tags_up"},
+			wantErr: "0002_add_posts_up is not applied but later migrations are, golang-migrate cannot record that",
+		},
+		{
+			name:    "dbmate in a custom table",
+			tool:    "dbmate",
+			table:   "dbmate_history",
+			applied: []string{"0002_add_posts_up", "unrelated_up"},
+			list:    numbered,
+			want:    map[string]bool{"0002": true},
+		},
+		{
+			name:    "flyway",
+			tool:    "flyway",
+			applied: []string{"0001_add_users_up", "0002_add_posts_up"},
+			list:    dotted,
+			want:    map[string]bool{"1": true, "2": true},
+		},
+		{
+			name:    "invalid table name",
+			tool:    "dbmate",
+			table:   "history;",
+			wantErr: `invalid history table name "history;"`,
+		},
+		{
+			name:    "unknown tool",
+			tool:    "liquibase",
+			wantErr: `unsupported tool "liquibase": use dbmate, flyway, goose, migrate`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, err := HistoryStatements(tt.tool, "sqlite", tt.table, exported, tt.applied)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("HistoryStatements() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HistoryStatements() error = %v", err)
+			}
+
+			driver := openSQLite(t, statements)
+			got, err := appliedVersions(context.Background(), driver, tt.tool, tt.table, tt.list)
+			if err != nil {
+				t.Fatalf("reading the history back: %v\n%s", err, statements)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("history read back = %v, want %v\n%s", got, tt.want, statements)
+			}
+		})
+	}
+}
+
+func TestHistoryStatementsFlywayRows(t *testing.T) {
+	exported := []Exported{{ID: "0001_add_users_up", Version: "0001", Name: "add_users", Script: "V1__add_users.sql", Up: "CREATE TABLE users (id int);\n"}}
+
+	statements, err := HistoryStatements("flyway", "postgres", "", exported, []string{"0001_add_users_up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "VALUES (1, '1', 'add users', 'SQL', 'V1__add_users.sql', " + strconv.Itoa(int(flywayChecksum("CREATE TABLE users (id int);\n"))) + ", 'vagabond', 0, TRUE);"
+	if !strings.Contains(statements, want) {
+		t.Errorf("HistoryStatements() = %s, want a row with %s", statements, want)
+	}
+}
+
+func TestTrimZeros(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"0042", "42"},
+		{"20240117093012", "20240117093012"},
+		{"0000", "0"},
+		{"", "0"},
+	}
+
+	for _, tt := range tests {
+		if got := trimZeros(tt.version); got != tt.want {
+			t.Errorf("trimZeros(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestFlywayChecksum(t *testing.T) {
+	want := flywayChecksum("CREATE TABLE users (id int);\nDROP TABLE old;")
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"trailing line break", "CREATE TABLE users (id int);\nDROP TABLE old;\n"},
+		{"windows line breaks", "CREATE TABLE users (id int);\r\nDROP TABLE old;\r\n"},
+		{"byte order mark", "\uFEFFCREATE TABLE users (id int);\nDROP TABLE old;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flywayChecksum(tt.content); got != want {
+				t.Errorf("flywayChecksum() = %d, want %d", got, want)
+			}
+		})
+	}
+
+	// the line breaks themselves are not part of the checksum
+	if got, other := flywayChecksum("ab"), flywayChecksum("a\nb"); got != other {
+		t.Errorf("flywayChecksum(%q) = %d, want %d", "a\nb", other, got)
+	}
+	if got := flywayChecksum("ab\nc"); got == want {
+		t.Errorf("flywayChecksum() of a different script = %d, want something else", got)
+	}
+}
@@ -0,0 +1,187 @@
+package interop
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+// HistoryTable is the table each tool records applied migrations in.
+func HistoryTable(tool string) string {
+	switch tool {
+	case "goose":
+		return "goose_db_version"
+	case "flyway":
+		return "flyway_schema_history"
+	default:
+		return "schema_migrations"
+	}
+}
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][\w.]*$`)
+
+// applied reads the versions the tool's history table lists as applied.
+func applied(ctx context.Context, driver db.Driver, tool, table string, list []Migration) (map[string]bool, error) {
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid history table name %q", table)
+	}
+
+	var (
+		result map[string]bool
+		err    error
+	)
+	switch tool {
+	case "migrate":
+		result, err = migrateApplied(ctx, driver, table, list)
+	case "goose":
+		result, err = gooseApplied(ctx, driver, table, list)
+	case "dbmate":
+		result, err = dbmateApplied(ctx, driver, table)
+	case "flyway":
+		result, err = flywayApplied(ctx, driver, table, list)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	return result, nil
+}
+
+// migrateApplied handles golang-migrate, which only keeps the current
+// version: everything up to it is applied.
+func migrateApplied(ctx context.Context, driver db.Driver, table string, list []Migration) (map[string]bool, error) {
+	rows, err := driver.Query(ctx, "SELECT version, dirty FROM "+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]bool{}
+	if !rows.Next() {
+		return result, rows.Err()
+	}
+	var (
+		version uint64
+		dirty   bool
+	)
+	if err := rows.Scan(&version, &dirty); err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("version %d is dirty, fix the database and run migrate force first", version)
+	}
+
+	for _, m := range list {
+		if n, err := strconv.ParseUint(m.Version, 10, 64); err == nil && n <= version {
+			result[m.Version] = true
+		}
+	}
+	return result, nil
+}
+
+// gooseApplied replays goose's log of applies and rollbacks.
+func gooseApplied(ctx context.Context, driver db.Driver, table string, list []Migration) (map[string]bool, error) {
+	rows, err := driver.Query(ctx, "SELECT version_id, is_applied FROM "+table+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	state := map[uint64]bool{}
+	for rows.Next() {
+		var (
+			version   uint64
+			isApplied bool
+		)
+		if err := rows.Scan(&version, &isApplied); err != nil {
+			return nil, err
+		}
+		state[version] = isApplied
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := map[string]bool{}
+	for _, m := range list {
+		if n, err := strconv.ParseUint(m.Version, 10, 64); err == nil && state[n] {
+			result[m.Version] = true
+		}
+	}
+	return result, nil
+}
+
+func dbmateApplied(ctx context.Context, driver db.Driver, table string) (map[string]bool, error) {
+	rows, err := driver.Query(ctx, "SELECT version FROM "+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		result[version] = true
+	}
+	return result, rows.Err()
+}
+
+// flywayApplied replays Flyway's history, where undo rows revert a version
+// and a baseline row stands for every version up to it.
+func flywayApplied(ctx context.Context, driver db.Driver, table string, list []Migration) (map[string]bool, error) {
+	rows, err := driver.Query(ctx, "SELECT version, type, success FROM "+table+" WHERE version IS NOT NULL ORDER BY installed_rank")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]bool{}
+	baseline := ""
+	for rows.Next() {
+		var (
+			version, kind string
+			success       bool
+		)
+		if err := rows.Scan(&version, &kind, &success); err != nil {
+			return nil, err
+		}
+		if !success {
+			return nil, fmt.Errorf("version %s failed, repair the history with flyway repair first", version)
+		}
+		version = normalizeFlyway(version)
+		switch kind {
+		case "BASELINE":
+			baseline = version
+		case "UNDO_SQL":
+			delete(result, version)
+		default:
+			result[version] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, m := range list {
+		if version := normalizeFlyway(m.Version); result[version] || (baseline != "" && !flywayLess(baseline, version)) {
+			result[m.Version] = true
+		}
+	}
+	return result, nil
+}
+
+// normalizeFlyway drops the leading zeros Flyway ignores, V001 and 1 are
+// the same version.
+func normalizeFlyway(version string) string {
+	parts := strings.Split(version, ".")
+	for i, part := range parts {
+		parts[i] = trimZeros(part)
+	}
+	return strings.Join(parts, ".")
+}
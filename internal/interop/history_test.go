@@ -0,0 +1,153 @@
+package interop
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+// openSQLite opens a database holding the tables and rows of ddl.
+func openSQLite(t *testing.T, ddl string) db.Driver {
+	t.Helper()
+	ctx := context.Background()
+	driver, err := db.New(ctx, db.Config{Type: "sqlite", DSN: filepath.Join(t.TempDir(), "history.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { driver.Close() })
+	if ddl != "" {
+		if err := driver.Exec(ctx, ddl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return driver
+}
+
+func TestApplied(t *testing.T) {
+	numbered := []Migration{{Version: "0001"}, {Version: "0002"}, {Version: "0003"}}
+	dotted := []Migration{{Version: "1"}, {Version: "1.1"}, {Version: "1.2"}, {Version: "2"}, {Version: "3"}}
+
+	tests := []struct {
+		name    string
+		tool    string
+		table   string
+		ddl     string
+		list    []Migration
+		want    map[string]bool
+		wantErr string
+	}{
+		{
+			name: "migrate applies everything up to its version",
+			tool: "migrate",
+			ddl:  "CREATE TABLE schema_migrations (version bigint, dirty boolean); INSERT INTO schema_migrations VALUES (2, FALSE);",
+			list: numbered,
+			want: map[string]bool{"0001": true, "0002": true},
+		},
+		{
+			name: "migrate without a version",
+			tool: "migrate",
+			ddl:  "CREATE TABLE schema_migrations (version bigint, dirty boolean);",
+			list: numbered,
+			want: map[string]bool{},
+		},
+		{
+			name:    "migrate dirty",
+			tool:    "migrate",
+			ddl:     "CREATE TABLE schema_migrations (version bigint, dirty boolean); INSERT INTO schema_migrations VALUES (2, TRUE);",
+			list:    numbered,
+			wantErr: "failed to read schema_migrations: version 2 is dirty, fix the database and run migrate force first",
+		},
+		{
+			name: "goose replays rollbacks",
+			tool: "goose",
+			ddl: `CREATE TABLE goose_db_version (id INTEGER PRIMARY KEY, version_id bigint, is_applied boolean);
+INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, TRUE), (1, TRUE), (2, TRUE), (3, TRUE), (3, FALSE), (2, FALSE), (2, TRUE);`,
+			list: numbered,
+			want: map[string]bool{"0001": true, "0002": true},
+		},
+		{
+			name: "dbmate",
+			tool: "dbmate",
+			ddl:  "CREATE TABLE schema_migrations (version varchar(128)); INSERT INTO schema_migrations VALUES ('0001'), ('0003');",
+			list: numbered,
+			want: map[string]bool{"0001": true, "0003": true},
+		},
+		{
+			name:  "custom table",
+			tool:  "dbmate",
+			table: "dbmate_history",
+			ddl:   "CREATE TABLE dbmate_history (version varchar(128)); INSERT INTO dbmate_history VALUES ('0002');",
+			list:  numbered,
+			want:  map[string]bool{"0002": true},
+		},
+		{
+			name: "flyway baseline and undo",
+			tool: "flyway",
+			ddl: `CREATE TABLE flyway_schema_history (installed_rank integer, version varchar(50), type varchar(20), success boolean);
+INSERT INTO flyway_schema_history VALUES (1, '1.1', 'BASELINE', TRUE), (2, NULL, 'SQL', TRUE), (3, '02', 'SQL', TRUE), (4, '3', 'SQL', TRUE), (5, '3', 'UNDO_SQL', TRUE);`,
+			list: dotted,
+			want: map[string]bool{"1": true, "1.1": true, "2": true},
+		},
+		{
+			name:    "flyway failed migration",
+			tool:    "flyway",
+			ddl:     "CREATE TABLE flyway_schema_history (installed_rank integer, version varchar(50), type varchar(20), success boolean); INSERT INTO flyway_schema_history VALUES (1, '1', 'SQL', FALSE);",
+			list:    dotted,
+			wantErr: "failed to read flyway_schema_history: version 1 failed, repair the history with flyway repair first",
+		},
+		{
+			name:    "missing table",
+			tool:    "dbmate",
+			list:    numbered,
+			wantErr: "failed to read schema_migrations: no such table: schema_migrations",
+		},
+		{
+			name:    "invalid table name",
+			tool:    "dbmate",
+			table:   "migrations; DROP TABLE users",
+			list:    numbered,
+			wantErr: `invalid history table name "migrations; DROP TABLE users"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := openSQLite(t, tt.ddl)
+
+			got, err := appliedVersions(context.Background(), driver, tt.tool, tt.table, tt.list)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("appliedVersions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("appliedVersions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("appliedVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeFlyway(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"1", "1"},
+		{"001", "1"},
+		{"1.01.0", "1.1.0"},
+		{"0", "0"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeFlyway(tt.version); got != tt.want {
+			t.Errorf("normalizeFlyway(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
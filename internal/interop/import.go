@@ -0,0 +1,151 @@
+package interop
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/migrations"
+)
+
+// Imported is a migration converted by Import.
+type Imported struct {
+	From    string // the tool's version
+	ID      string // the vagabond migration ID
+	Applied bool
+}
+
+// Import converts the migrations tool keeps in dir into vagabond's layout.
+// With a driver, the migrations listed as applied in the tool's history
+// table are recorded in vagabond_migrations so they don't run again.
+func Import(ctx context.Context, driver db.Driver, tool, dir, table string) ([]Imported, error) {
+	read, err := reader(tool)
+	if err != nil {
+		return nil, err
+	}
+
+	// importing twice would duplicate every migration, and mixing the
+	// imported history with existing migrations would make its order a guess
+	existing, err := migrations.UpFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("the migrations directory already has %d migration(s), import into an empty one", len(existing))
+	}
+	list, err := read(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no %s migrations found in %s", tool, dir)
+	}
+
+	versions, err := importVersions(tool, list)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := make([]Imported, len(list))
+	for i, m := range list {
+		imported[i] = Imported{From: m.Version, ID: versions[i] + "_" + identifier(m.Name) + "_up"}
+	}
+
+	var applied map[string]bool
+	if driver != nil {
+		if applied, err = appliedVersions(ctx, driver, tool, table, list); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, m := range list {
+		if err := migrations.CreateMigrationWithContent(versions[i], identifier(m.Name), upContent(m), downContent(m)); err != nil {
+			return nil, err
+		}
+		imported[i].Applied = applied[m.Version]
+	}
+
+	if driver != nil {
+		if err := record(ctx, driver, imported); err != nil {
+			return nil, err
+		}
+	}
+	return imported, nil
+}
+
+// importVersions keeps the numeric versions of goose, golang-migrate and
+// dbmate. Flyway's dotted versions don't fit vagabond's, so its migrations
+// get the next sequential versions, in Flyway's order.
+func importVersions(tool string, list []Migration) ([]string, error) {
+	versions := make([]string, len(list))
+	if tool != "flyway" {
+		for i, m := range list {
+			versions[i] = m.Version
+		}
+		return versions, nil
+	}
+
+	next, err := migrations.NextVersion(migrations.Sequential)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseUint(next, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		versions[i] = fmt.Sprintf("%0*d", len(next), n+uint64(i))
+	}
+	return versions, nil
+}
+
+func appliedVersions(ctx context.Context, driver db.Driver, tool, table string, list []Migration) (map[string]bool, error) {
+	if table == "" {
+		table = HistoryTable(tool)
+	}
+	return applied(ctx, driver, tool, table, list)
+}
+
+func record(ctx context.Context, driver db.Driver, imported []Imported) error {
+	if err := driver.Lock(ctx); err != nil {
+		return err
+	}
+	defer driver.Unlock()
+
+	recorded, err := driver.GetAppliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	for _, m := range imported {
+		if !m.Applied || recorded[m.ID] {
+			continue
+		}
+		if err := driver.RecordMigration(ctx, m.ID); err != nil {
+			return fmt.Errorf("failed to record %s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+func upContent(m Migration) string {
+	return annotate(m.Up, m.NoTransaction)
+}
+
+// downContent marks migrations without a down as irreversible, the tool had
+// no way to roll them back either.
+func downContent(m Migration) string {
+	if !m.HasDown {
+		return "-- vagabond:irreversible\n"
+	}
+	return annotate(m.Down, m.NoTransaction)
+}
+
+func annotate(sql string, noTransaction bool) string {
+	sql = strings.TrimSpace(sql) + "\n"
+	if noTransaction {
+		sql = "-- vagabond:no-transaction\n" + sql
+	}
+	return sql
+}
@@ -0,0 +1,59 @@
+package interop
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jxdones/vagabond/internal/migrations"
+)
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	t.Chdir(t.TempDir())
+	writeFiles(t, "flyway", map[string]string{
+		"V1__Create users.sql":  "CREATE TABLE users (id int);",
+		"U1__Create users.sql":  "DROP TABLE users;",
+		"V1_1__Add_posts.sql":   "CREATE TABLE posts (id int);",
+		"V2__Add_tags.sql":      "CREATE TABLE tags (id int);",
+		"V2__Add_tags.sql.conf": "executeInTransaction=false",
+	})
+	writeFiles(t, "migrations", nil)
+	driver := openSQLite(t, `CREATE TABLE flyway_schema_history (installed_rank integer, version varchar(50), type varchar(20), success boolean);
+INSERT INTO flyway_schema_history VALUES (1, '1', 'SQL', TRUE), (2, '1.1', 'SQL', TRUE);`)
+
+	imported, err := Import(ctx, driver, "flyway", "flyway", "")
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	want := []Imported{
+		{From: "1", ID: "0001_Create_users_up", Applied: true},
+		{From: "1.1", ID: "0002_Add_posts_up", Applied: true},
+		{From: "2", ID: "0003_Add_tags_up"},
+	}
+	if !reflect.DeepEqual(imported, want) {
+		t.Errorf("Import() = %+v, want %+v", imported, want)
+	}
+
+	recorded, err := driver.GetAppliedMigrationsList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recorded, []string{"0001_Create_users_up", "0002_Add_posts_up"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recorded %v, want %v", got, want)
+	}
+
+	down, err := os.ReadFile(filepath.Join("migrations", "0002_Add_posts_down.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !migrations.IsIrreversible(string(down)) {
+		t.Errorf("0002_Add_posts_down.sql = %q, want it marked irreversible", down)
+	}
+
+	if _, err := Import(ctx, nil, "flyway", "flyway", ""); err == nil || err.Error() != "the migrations directory already has 3 migration(s), import into an empty one" {
+		t.Errorf("Import() again error = %v, want a refusal", err)
+	}
+}
@@ -0,0 +1,239 @@
+// Package interop converts migrations between vagabond and other migration
+// tools: golang-migrate, goose, dbmate and Flyway.
+package interop
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a migration in another tool's layout.
+type Migration struct {
+	Version string // as the tool writes it, such as 20240117093012, 00042 or 1.1
+	Name    string
+	Up      string
+	Down    string
+	HasDown bool
+	// NoTransaction is set for migrations the tool runs outside of a
+	// transaction.
+	NoTransaction bool
+}
+
+// Tools lists the supported tools, as given to --from and --to.
+func Tools() []string {
+	return []string{"dbmate", "flyway", "goose", "migrate"}
+}
+
+func reader(tool string) (func(dir string) ([]Migration, error), error) {
+	switch tool {
+	case "goose":
+		return readGoose, nil
+	case "migrate":
+		return readMigrate, nil
+	case "dbmate":
+		return readDbmate, nil
+	case "flyway":
+		return readFlyway, nil
+	default:
+		return nil, fmt.Errorf("unsupported tool %q: use %s", tool, strings.Join(Tools(), ", "))
+	}
+}
+
+var (
+	gooseFile   = regexp.MustCompile(`^(\d+)_(.+)\.(sql|go)$`)
+	migrateFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	dbmateFile  = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+	flywayFile  = regexp.MustCompile(`^([VUR])([0-9._]*)__(.+)\.sql$`)
+	nonWord     = regexp.MustCompile(`\W+`)
+)
+
+func readGoose(dir string) ([]Migration, error) {
+	var list []Migration
+	err := eachFile(dir, func(name, content string) error {
+		match := gooseFile.FindStringSubmatch(name)
+		if match == nil {
+			return nil
+		}
+		if match[3] == "go" {
+			return fmt.Errorf("%s is a Go migration, rewrite it in SQL before importing", name)
+		}
+
+		m := Migration{Version: match[1], Name: match[2]}
+		var section *string
+		for _, line := range strings.SplitAfter(content, "\n") {
+			switch directive := strings.TrimSpace(line); {
+			case strings.HasPrefix(directive, "-- +goose Up"):
+				section = &m.Up
+			case strings.HasPrefix(directive, "-- +goose Down"):
+				section, m.HasDown = &m.Down, true
+			case strings.HasPrefix(directive, "-- +goose NO TRANSACTION"):
+				m.NoTransaction = true
+			case strings.HasPrefix(directive, "-- +goose "):
+				// StatementBegin/End and ENVSUB only matter to goose
+			case section != nil:
+				*section += line
+			}
+		}
+		list = append(list, m)
+		return nil
+	})
+	return sortNumeric(list), err
+}
+
+func readMigrate(dir string) ([]Migration, error) {
+	byVersion := map[string]*Migration{}
+	err := eachFile(dir, func(name, content string) error {
+		match := migrateFile.FindStringSubmatch(name)
+		if match == nil {
+			return nil
+		}
+		m := byVersion[match[1]]
+		if m == nil {
+			m = &Migration{Version: match[1], Name: match[2]}
+			byVersion[match[1]] = m
+		}
+		if match[3] == "up" {
+			m.Up = content
+		} else {
+			m.Down, m.HasDown = content, true
+		}
+		return nil
+	})
+
+	var list []Migration
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+	return sortNumeric(list), err
+}
+
+func readDbmate(dir string) ([]Migration, error) {
+	var list []Migration
+	err := eachFile(dir, func(name, content string) error {
+		match := dbmateFile.FindStringSubmatch(name)
+		if match == nil {
+			return nil
+		}
+
+		m := Migration{Version: match[1], Name: match[2]}
+		var section *string
+		for _, line := range strings.SplitAfter(content, "\n") {
+			switch directive := strings.TrimSpace(line); {
+			case strings.HasPrefix(directive, "-- migrate:up"):
+				section = &m.Up
+				m.NoTransaction = m.NoTransaction || strings.Contains(directive, "transaction:false")
+			case strings.HasPrefix(directive, "-- migrate:down"):
+				section, m.HasDown = &m.Down, true
+				m.NoTransaction = m.NoTransaction || strings.Contains(directive, "transaction:false")
+			case section != nil:
+				*section += line
+			}
+		}
+		list = append(list, m)
+		return nil
+	})
+	return sortNumeric(list), err
+}
+
+func readFlyway(dir string) ([]Migration, error) {
+	byVersion := map[string]*Migration{}
+	err := eachFile(dir, func(name, content string) error {
+		match := flywayFile.FindStringSubmatch(name)
+		if match == nil {
+			return nil
+		}
+		kind, version := match[1], flywayVersion(match[2])
+		if kind == "R" {
+			return fmt.Errorf("%s is a repeatable migration, which vagabond has no equivalent for", name)
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[3]}
+			byVersion[version] = m
+		}
+		if kind == "V" {
+			m.Up, m.Name = content, match[3]
+		} else {
+			m.Down, m.HasDown = content, true
+		}
+		return nil
+	})
+
+	var list []Migration
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return flywayLess(list[i].Version, list[j].Version) })
+	return list, err
+}
+
+// eachFile calls fn with the name and content of every file in dir, in name
+// order, and collects the errors so they are reported together.
+func eachFile(dir string, fn func(name, content string) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var problems []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("error reading file %s: %w", entry.Name(), err)
+		}
+		if err := fn(entry.Name(), string(data)); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("cannot convert %d file(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func sortNumeric(list []Migration) []Migration {
+	sort.Slice(list, func(i, j int) bool { return numericLess(list[i].Version, list[j].Version) })
+	return list
+}
+
+func numericLess(a, b string) bool {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return na < nb
+}
+
+// flywayVersion normalizes the underscores Flyway accepts in file names to
+// the dots of its history table.
+func flywayVersion(version string) string {
+	return strings.ReplaceAll(version, "_", ".")
+}
+
+func flywayLess(a, b string) bool {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] != pb[i] {
+			return numericLess(pa[i], pb[i])
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+// identifier turns a tool's description into a vagabond migration name.
+func identifier(name string) string {
+	name = strings.Trim(nonWord.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		return "migration"
+	}
+	return name
+}
@@ -0,0 +1,166 @@
+package interop
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles writes files, named relative to dir, creating dir as needed.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    string
+		files   map[string]string
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "goose",
+			tool: "goose",
+			files: map[string]string{
+				"00010_add_posts.sql": "-- +goose Up\nCREATE TABLE posts (id int);\n",
+				"00002_add_users.sql": "-- +goose NO TRANSACTION\n-- +goose Up\n-- +goose StatementBegin\nCREATE INDEX CONCURRENTLY users_email ON users (email);\n-- +goose StatementEnd\n\n-- +goose Down\nDROP INDEX users_email;\n",
+				"README.md":           "not a migration",
+			},
+			want: []Migration{
+				{Version: "00002", Name: "add_users", Up: "CREATE INDEX CONCURRENTLY users_email ON users (email);\n\n", Down: "DROP INDEX users_email;\n", HasDown: true, NoTransaction: true},
+				{Version: "00010", Name: "add_posts", Up: "CREATE TABLE posts (id int);\n"},
+			},
+		},
+		{
+			name:    "goose go migration",
+			tool:    "goose",
+			files:   map[string]string{"00001_seed.go": "package migrations"},
+			wantErr: "cannot convert 1 file(s):\n  00001_seed.go is a Go migration, rewrite it in SQL before importing",
+		},
+		{
+			name: "migrate",
+			tool: "migrate",
+			files: map[string]string{
+				"2_add_posts.up.sql":   "CREATE TABLE posts (id int);",
+				"10_add_tags.up.sql":   "CREATE TABLE tags (id int);",
+				"10_add_tags.down.sql": "DROP TABLE tags;",
+			},
+			want: []Migration{
+				{Version: "2", Name: "add_posts", Up: "CREATE TABLE posts (id int);"},
+				{Version: "10", Name: "add_tags", Up: "CREATE TABLE tags (id int);", Down: "DROP TABLE tags;", HasDown: true},
+			},
+		},
+		{
+			name: "dbmate",
+			tool: "dbmate",
+			files: map[string]string{
+				"20240117093012_add_users.sql": "-- migrate:up transaction:false\nCREATE INDEX CONCURRENTLY users_email ON users (email);\n\n-- migrate:down\nDROP INDEX users_email;\n",
+			},
+			want: []Migration{
+				{Version: "20240117093012", Name: "add_users", Up: "CREATE INDEX CONCURRENTLY users_email ON users (email);\n\n", Down: "DROP INDEX users_email;\n", HasDown: true, NoTransaction: true},
+			},
+		},
+		{
+			name: "flyway",
+			tool: "flyway",
+			files: map[string]string{
+				"V1_10__Add_tags.sql":  "CREATE TABLE tags (id int);",
+				"V1_2__Add_posts.sql":  "CREATE TABLE posts (id int);",
+				"U1_2__Add_posts.sql":  "DROP TABLE posts;",
+				"V1__Init.sql":         "CREATE TABLE users (id int);",
+				"V1__Init.sql.conf":    "executeInTransaction=false",
+				"flyway.conf":          "flyway.url=jdbc:postgresql://localhost/app",
+				"V1_2__Add_posts.conf": "ignored",
+			},
+			want: []Migration{
+				{Version: "1", Name: "Init", Up: "CREATE TABLE users (id int);"},
+				{Version: "1.2", Name: "Add_posts", Up: "CREATE TABLE posts (id int);", Down: "DROP TABLE posts;", HasDown: true},
+				{Version: "1.10", Name: "Add_tags", Up: "CREATE TABLE tags (id int);"},
+			},
+		},
+		{
+			name:    "flyway repeatable migration",
+			tool:    "flyway",
+			files:   map[string]string{"R__views.sql": "CREATE VIEW v AS SELECT 1;"},
+			wantErr: "cannot convert 1 file(s):\n  R__views.sql is a repeatable migration, which vagabond has no equivalent for",
+		},
+		{
+			name:    "unknown tool",
+			tool:    "liquibase",
+			wantErr: `unsupported tool "liquibase": use dbmate, flyway, goose, migrate`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			read, err := reader(tt.tool)
+			var got []Migration
+			if err == nil {
+				got, err = read(dir)
+			}
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlywayLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1", "2", true},
+		{"1.2", "1.10", true},
+		{"1.10", "1.2", false},
+		{"1", "1.1", true},
+		{"2", "1.9", false},
+		{"1.1", "1.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := flywayLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("flywayLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"add_users", "add_users"},
+		{"Add users table", "Add_users_table"},
+		{"add-users.v2", "add_users_v2"},
+		{"--", "migration"},
+	}
+
+	for _, tt := range tests {
+		if got := identifier(tt.name); got != tt.want {
+			t.Errorf("identifier(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
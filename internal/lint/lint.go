@@ -0,0 +1,171 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/sqlscript"
+)
+
+type Level string
+
+const (
+	Error   Level = "error"
+	Warning Level = "warning"
+	Off     Level = "off"
+)
+
+type Rule struct {
+	Name     string
+	Level    Level
+	Dialects []string // empty means every dialect
+	Message  string
+	match    func(stmt string) bool
+}
+
+type Finding struct {
+	File    string
+	Line    int
+	Rule    string
+	Level   Level
+	Message string
+}
+
+var (
+	addColumn       = regexp.MustCompile(`(?i)\bALTER\s+TABLE\b.*\bADD\s+(COLUMN\s+)?`)
+	notNull         = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	hasDefault      = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+	createIndex     = regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\b`)
+	concurrently    = regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\s+CONCURRENTLY\b`)
+	dropTable       = regexp.MustCompile(`(?i)^DROP\s+TABLE\b`)
+	dropColumn      = regexp.MustCompile(`(?i)\bALTER\s+TABLE\b.*\bDROP\s+(COLUMN\s+)?`)
+	dropConstraint  = regexp.MustCompile(`(?i)\bDROP\s+CONSTRAINT\b`)
+	renameStatement = regexp.MustCompile(`(?i)\bALTER\s+TABLE\b.*\bRENAME\b`)
+	alterType       = regexp.MustCompile(`(?i)\bALTER\s+TABLE\b.*\bALTER\s+(COLUMN\s+)?\S+\s+(SET\s+DATA\s+)?TYPE\b`)
+	addConstraint   = regexp.MustCompile(`(?i)\bADD\s+(CONSTRAINT\s+\S+\s+)?(PRIMARY\s+KEY|UNIQUE|FOREIGN\s+KEY|CHECK|CONSTRAINT)\b`)
+)
+
+func Rules() []Rule {
+	return []Rule{
+		{
+			Name:    "not-null-without-default",
+			Level:   Error,
+			Message: "adding a NOT NULL column without a DEFAULT fails on tables that already have rows",
+			match: func(stmt string) bool {
+				loc := addColumn.FindStringIndex(stmt)
+				if loc == nil || addConstraint.MatchString(stmt[loc[0]:]) {
+					return false
+				}
+				column := stmt[loc[1]:]
+				return notNull.MatchString(column) && !hasDefault.MatchString(column)
+			},
+		},
+		{
+			Name:     "index-without-concurrently",
+			Level:    Warning,
+			Dialects: []string{"postgres"},
+			Message:  "CREATE INDEX without CONCURRENTLY blocks writes to the table while the index builds",
+			match: func(stmt string) bool {
+				return createIndex.MatchString(stmt) && !concurrently.MatchString(stmt)
+			},
+		},
+		{
+			Name:    "drop-table",
+			Level:   Error,
+			Message: "DROP TABLE permanently removes the table and its data",
+			match:   dropTable.MatchString,
+		},
+		{
+			Name:    "drop-column",
+			Level:   Warning,
+			Message: "dropping a column loses its data and breaks code still reading it",
+			match: func(stmt string) bool {
+				return dropColumn.MatchString(stmt) && !dropConstraint.MatchString(stmt)
+			},
+		},
+		{
+			Name:    "rename",
+			Level:   Warning,
+			Message: "renaming a table or column breaks code still using the old name",
+			match:   renameStatement.MatchString,
+		},
+		{
+			Name:     "alter-column-type",
+			Level:    Warning,
+			Dialects: []string{"postgres"},
+			Message:  "changing a column type can rewrite the whole table under an exclusive lock",
+			match:    alterType.MatchString,
+		},
+	}
+}
+
+// Configure applies rule=level overrides on top of the default levels.
+func Configure(overrides map[string]string) ([]Rule, error) {
+	rules := Rules()
+	for name, value := range overrides {
+		level := Level(strings.ToLower(value))
+		if level != Error && level != Warning && level != Off {
+			return nil, fmt.Errorf("invalid level %q for rule %s: use error, warning or off", value, name)
+		}
+
+		i := slices.IndexFunc(rules, func(r Rule) bool { return r.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown lint rule: %s", name)
+		}
+		rules[i].Level = level
+	}
+	return rules, nil
+}
+
+func LintFile(path, dialect string, rules []Rule) ([]Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", path, err)
+	}
+	return Lint(path, string(data), dialect, rules), nil
+}
+
+func Lint(name, sql, dialect string, rules []Rule) []Finding {
+	var findings []Finding
+	for _, stmt := range sqlscript.Split(sql) {
+		for _, rule := range rules {
+			if rule.Level == Off {
+				continue
+			}
+			if len(rule.Dialects) > 0 && !slices.Contains(rule.Dialects, dialect) {
+				continue
+			}
+			if rule.match(stmt.Text) {
+				findings = append(findings, Finding{
+					File:    name,
+					Line:    stmt.Line,
+					Rule:    rule.Name,
+					Level:   rule.Level,
+					Message: rule.Message,
+				})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s [%s] %s", f.File, f.Line, f.Level, f.Rule, f.Message)
+}
+
+func Count(findings []Finding) (errors, warnings int) {
+	for _, f := range findings {
+		switch f.Level {
+		case Error:
+			errors++
+		case Warning:
+			warnings++
+		}
+	}
+	return errors, warnings
+}
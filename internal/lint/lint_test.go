@@ -0,0 +1,147 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect string
+		want    []string // the rules found, in order
+	}{
+		{name: "add nullable column", sql: "ALTER TABLE users ADD COLUMN name text;", dialect: "postgres"},
+		{name: "add not null column", sql: "ALTER TABLE users ADD COLUMN name text NOT NULL;", dialect: "postgres", want: []string{"not-null-without-default"}},
+		{name: "add not null column without COLUMN", sql: "alter table users add name text not null;", dialect: "sqlite", want: []string{"not-null-without-default"}},
+		{name: "add not null column with default", sql: "ALTER TABLE users ADD COLUMN name text NOT NULL DEFAULT '';", dialect: "postgres"},
+		{name: "add constraint", sql: "ALTER TABLE users ADD CONSTRAINT name_not_null CHECK (name IS NOT NULL);", dialect: "postgres"},
+		{name: "add primary key", sql: "ALTER TABLE users ADD PRIMARY KEY (id);", dialect: "postgres"},
+		{name: "index on postgres", sql: "CREATE INDEX users_name ON users (name);", dialect: "postgres", want: []string{"index-without-concurrently"}},
+		{name: "unique index on postgres", sql: "CREATE UNIQUE INDEX users_name ON users (name);", dialect: "postgres", want: []string{"index-without-concurrently"}},
+		{name: "index concurrently", sql: "CREATE INDEX CONCURRENTLY users_name ON users (name);", dialect: "postgres"},
+		{name: "index on sqlite", sql: "CREATE INDEX users_name ON users (name);", dialect: "sqlite"},
+		{name: "drop table", sql: "DROP TABLE users;", dialect: "sqlite", want: []string{"drop-table"}},
+		{name: "drop column", sql: "ALTER TABLE users DROP COLUMN name;", dialect: "postgres", want: []string{"drop-column"}},
+		{name: "drop constraint", sql: "ALTER TABLE users DROP CONSTRAINT users_name_key;", dialect: "postgres"},
+		{name: "rename column", sql: "ALTER TABLE users RENAME COLUMN name TO full_name;", dialect: "postgres", want: []string{"rename"}},
+		{name: "rename table", sql: "ALTER TABLE users RENAME TO people;", dialect: "sqlite", want: []string{"rename"}},
+		{name: "alter column type", sql: "ALTER TABLE users ALTER COLUMN id TYPE bigint;", dialect: "postgres", want: []string{"alter-column-type"}},
+		{name: "alter column set data type", sql: "ALTER TABLE users ALTER id SET DATA TYPE bigint;", dialect: "postgres", want: []string{"alter-column-type"}},
+		{name: "alter column default", sql: "ALTER TABLE users ALTER COLUMN id SET DEFAULT 0;", dialect: "postgres"},
+		{name: "in a comment", sql: "-- DROP TABLE users;\nSELECT 1;", dialect: "postgres"},
+		{name: "in a string", sql: "INSERT INTO notes (body) VALUES ('DROP TABLE users');", dialect: "postgres"},
+		{
+			name:    "several statements",
+			sql:     "DROP TABLE old;\nCREATE INDEX users_name ON users (name);\nALTER TABLE users DROP COLUMN name;",
+			dialect: "postgres",
+			want:    []string{"drop-table", "index-without-concurrently", "drop-column"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range Lint("m.sql", tt.sql, tt.dialect, Rules()) {
+				got = append(got, f.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		want      map[string]Level // the levels checked
+		wantErr   string
+	}{
+		{
+			name: "defaults",
+			want: map[string]Level{"drop-table": Error, "drop-column": Warning},
+		},
+		{
+			name:      "overrides",
+			overrides: map[string]string{"drop-table": "warning", "drop-column": "ERROR", "rename": "off"},
+			want:      map[string]Level{"drop-table": Warning, "drop-column": Error, "rename": Off},
+		},
+		{
+			name:      "unknown rule",
+			overrides: map[string]string{"drop-everything": "error"},
+			wantErr:   "unknown lint rule: drop-everything",
+		},
+		{
+			name:      "invalid level",
+			overrides: map[string]string{"drop-table": "fatal"},
+			wantErr:   `invalid level "fatal" for rule drop-table: use error, warning or off`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := Configure(tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Configure() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Configure() error = %v", err)
+			}
+			for _, rule := range rules {
+				if want, ok := tt.want[rule.Name]; ok && rule.Level != want {
+					t.Errorf("level of %s = %s, want %s", rule.Name, rule.Level, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLintLevels(t *testing.T) {
+	rules, err := Configure(map[string]string{"drop-column": "off", "rename": "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql := "ALTER TABLE users DROP COLUMN a;\n\nALTER TABLE users RENAME TO people;\nDROP TABLE old;\nCREATE INDEX i ON t (c);\n"
+
+	findings := Lint("0001_up.sql", sql, "postgres", rules)
+	want := []Finding{
+		{File: "0001_up.sql", Line: 3, Rule: "rename", Level: Error, Message: "renaming a table or column breaks code still using the old name"},
+		{File: "0001_up.sql", Line: 4, Rule: "drop-table", Level: Error, Message: "DROP TABLE permanently removes the table and its data"},
+		{File: "0001_up.sql", Line: 5, Rule: "index-without-concurrently", Level: Warning, Message: "CREATE INDEX without CONCURRENTLY blocks writes to the table while the index builds"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("Lint() = %+v, want %+v", findings, want)
+	}
+	if errors, warnings := Count(findings); errors != 2 || warnings != 1 {
+		t.Errorf("Count() = %d, %d, want 2, 1", errors, warnings)
+	}
+	if got, want := findings[0].String(), "0001_up.sql:3: error [rename] renaming a table or column breaks code still using the old name"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestLintFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "0001_up.sql")
+	if err := os.WriteFile(path, []byte("DROP TABLE users;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := LintFile(path, "sqlite", Rules())
+	if err != nil {
+		t.Fatalf("LintFile() error = %v", err)
+	}
+	if len(findings) != 1 || findings[0].File != path || findings[0].Rule != "drop-table" {
+		t.Errorf("LintFile() = %+v", findings)
+	}
+
+	if _, err := LintFile(filepath.Join(t.TempDir(), "missing.sql"), "sqlite", Rules()); err == nil {
+		t.Error("LintFile() of a missing file succeeded, want an error")
+	}
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Setup installs the default logger. JSON logs go to stderr so they never
+// mix with a command's output; text logs write informational messages to
+// out and warnings and errors to stderr.
+func Setup(format string, level slog.Level, out io.Writer) error {
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = NewTextHandler(out, os.Stderr, level)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	default:
+		return fmt.Errorf("unsupported log format: %s (use text or json)", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// TextHandler writes one human readable line per record: the message
+// followed by its attributes as key=value pairs, without a timestamp.
+type TextHandler struct {
+	out, errOut io.Writer
+	level       slog.Level
+	attrs       []slog.Attr
+	mu          *sync.Mutex
+}
+
+func NewTextHandler(out, errOut io.Writer, level slog.Level) *TextHandler {
+	return &TextHandler{out: out, errOut: errOut, level: level, mu: &sync.Mutex{}}
+}
+
+func (h *TextHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *TextHandler) Handle(_ context.Context, r slog.Record) error {
+	var line strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		line.WriteString("error: ")
+	case r.Level >= slog.LevelWarn:
+		line.WriteString("warning: ")
+	}
+	line.WriteString(r.Message)
+
+	write := func(a slog.Attr) bool {
+		if !a.Equal(slog.Attr{}) {
+			line.WriteString(" " + a.Key + "=" + formatValue(a.Value))
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	line.WriteString("\n")
+
+	w := h.out
+	if r.Level >= slog.LevelWarn {
+		w = h.errOut
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(w, line.String())
+	return err
+}
+
+func (h *TextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+// WithGroup is not used by vagabond, groups are flattened into the line.
+func (h *TextHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+func formatValue(v slog.Value) string {
+	v = v.Resolve()
+	var s string
+	switch v.Kind() {
+	case slog.KindDuration:
+		s = v.Duration().Round(time.Microsecond).String()
+	default:
+		s = v.String()
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
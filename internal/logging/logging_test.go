@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestTextHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  slog.Level
+		log    func(l *slog.Logger)
+		out    string
+		errOut string
+	}{
+		{
+			name: "info goes to out",
+			log: func(l *slog.Logger) {
+				l.Info("Applied migration", "migration", "0001_add_users_up", "duration", 1234567*time.Nanosecond)
+			},
+			out: "Applied migration migration=0001_add_users_up duration=1.235ms\n",
+		},
+		{
+			name: "warnings and errors go to errOut",
+			log: func(l *slog.Logger) {
+				l.Warn("Retrying after transient error", "attempt", 1)
+				l.Error("Migration failed", "error", errors.New("syntax error"))
+			},
+			errOut: "warning: Retrying after transient error attempt=1\nerror: Migration failed error=\"syntax error\"\n",
+		},
+		{
+			name: "values needing quotes",
+			log: func(l *slog.Logger) {
+				l.Info("Quoted", "empty", "", "equals", "a=b", "quote", `say "hi"`, "line", "a\nb", "plain", "ok")
+			},
+			out: `Quoted empty="" equals="a=b" quote="say \"hi\"" line="a\nb" plain=ok` + "\n",
+		},
+		{
+			name: "logger attributes come first",
+			log: func(l *slog.Logger) {
+				l.With("database", "app.db").With("schema", "tenant_1").Info("Applied migrations", "count", 2)
+			},
+			out: "Applied migrations database=app.db schema=tenant_1 count=2\n",
+		},
+		{
+			name: "groups are flattened",
+			log: func(l *slog.Logger) {
+				l.WithGroup("hook").Info("Ran hook", "file", "after.sql")
+			},
+			out: "Ran hook file=after.sql\n",
+		},
+		{
+			name:  "records below the level are dropped",
+			level: slog.LevelWarn,
+			log: func(l *slog.Logger) {
+				l.Info("Applied migration")
+				l.Debug("Connected")
+				l.Warn("Slow")
+			},
+			errOut: "warning: Slow\n",
+		},
+		{
+			name:  "debug",
+			level: slog.LevelDebug,
+			log: func(l *slog.Logger) {
+				l.Debug("Connected", "dsn", "app.db")
+			},
+			out: "Connected dsn=app.db\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			tt.log(slog.New(NewTextHandler(&out, &errOut, tt.level)))
+			if out.String() != tt.out {
+				t.Errorf("out = %q, want %q", out.String(), tt.out)
+			}
+			if errOut.String() != tt.errOut {
+				t.Errorf("errOut = %q, want %q", errOut.String(), tt.errOut)
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr string
+	}{
+		{format: "", want: "Applied migrations count=2\n"},
+		{format: "text", want: "Applied migrations count=2\n"},
+		// JSON logs go to stderr, leaving out empty
+		{format: "json", want: ""},
+		{format: "xml", wantErr: "unsupported log format: xml (use text or json)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Setup(tt.format, slog.LevelInfo, &out)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Setup() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Setup() error = %v", err)
+			}
+			if tt.format == "json" {
+				if _, ok := slog.Default().Handler().(*slog.JSONHandler); !ok {
+					t.Errorf("Setup() handler = %T, want *slog.JSONHandler", slog.Default().Handler())
+				}
+			}
+			slog.Info("Applied migrations", "count", 2)
+			if out.String() != tt.want {
+				t.Errorf("out = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
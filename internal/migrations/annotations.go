@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/sqlscript"
+)
+
+// IsIrreversible reports whether a migration's SQL carries the
+// "-- vagabond:irreversible" annotation on a line of its own.
+func IsIrreversible(content string) bool {
+	return sqlscript.HasAnnotation(content, "irreversible")
+}
+
+// irreversible checks both files of a migration, since the annotation can be
+// written in the up file or in place of the down file's statements.
+func irreversible(upFile string) (bool, error) {
+	downFile := filepath.Join(filepath.Dir(upFile), downFileName(filepath.Base(upFile)))
+	for _, file := range []string{upFile, downFile} {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("error reading file %s: %w", file, err)
+		}
+		if IsIrreversible(string(data)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func Irreversible() ([]string, error) {
+	files, err := UpFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, f := range files {
+		ok, err := irreversible(f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids = append(ids, strings.TrimSuffix(filepath.Base(f), ".sql"))
+		}
+	}
+	return ids, nil
+}
@@ -1,22 +1,29 @@
 package migrations
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
+	"github.com/jxdones/vagabond/hooks"
 	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/retry"
+	"github.com/jxdones/vagabond/internal/sqlscript"
 )
 
 const migrationsPath = "migrations"
 
-func CreateMigration(name string) error {
-	timestamp := time.Now().Format("20060102150405")
-	upFileName := fmt.Sprintf("%s_%s_up.sql", timestamp, name)
-	downFileName := fmt.Sprintf("%s_%s_down.sql", timestamp, name)
+func CreateMigration(version, name string) error {
+	return CreateMigrationWithContent(version, name, "--  Write your SQL to apply this migration.\n", "-- Write your SQL to rollback this migration.\n")
+}
+
+func CreateMigrationWithContent(version, name, up, down string) error {
+	upFileName := fmt.Sprintf("%s_%s_up.sql", version, name)
+	downFileName := fmt.Sprintf("%s_%s_down.sql", version, name)
 
 	upFilePath := fmt.Sprintf("%s/%s", migrationsPath, upFileName)
 	downFilePath := fmt.Sprintf("%s/%s", migrationsPath, downFileName)
@@ -33,62 +40,165 @@ func CreateMigration(name string) error {
 	}
 	defer downFile.Close()
 
-	upFile.WriteString(fmt.Sprintf("-- %s\n--  Write your SQL to apply this migration.\n", upFileName))
-	downFile.WriteString(fmt.Sprintf("-- %s\n-- Write your SQL to rollback this migration.\n", downFileName))
-	fmt.Printf("%s created\n", upFilePath)
-	fmt.Printf("%s created\n", downFilePath)
+	upFile.WriteString(fmt.Sprintf("-- %s\n%s", upFileName, up))
+	downFile.WriteString(fmt.Sprintf("-- %s\n%s", downFileName, down))
+	slog.Info("Created migration file", "file", upFilePath)
+	slog.Info("Created migration file", "file", downFilePath)
 	return nil
 }
 
-func ApplyMigrations(driver db.Driver, dbType string) error {
-	applied, err := driver.GetAppliedMigrations()
-	if err != nil {
-		return fmt.Errorf("could not get applied migrations: %w", err)
+type ApplyOptions struct {
+	// AllowOutOfOrder applies pending migrations that sort before the
+	// latest applied one, typically merged from a long-lived branch.
+	AllowOutOfOrder bool
+	// Logger receives progress events, slog.Default() when nil.
+	Logger *slog.Logger
+	// Retry reruns a migration whose transaction failed with a transient
+	// error such as a deadlock or a dropped connection.
+	Retry retry.Policy
+	Hooks Hooks
+	// Vars resolves the ${name} placeholders in migrations and SQL hooks.
+	Vars func(name string) (string, bool)
+}
+
+type RollbackOptions struct {
+	Logger *slog.Logger
+	Retry  retry.Policy
+	Hooks  Hooks
+	Vars   func(name string) (string, bool)
+}
+
+const (
+	Applied    = "applied"
+	RolledBack = "rolled_back"
+	Failed     = "failed"
+	Skipped    = "skipped" // not attempted because an earlier migration failed
+)
+
+// Result is the outcome of one migration in a pack or unpack run.
+type Result struct {
+	ID       string
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.Default()
 	}
+	return l
+}
+
+func ApplyMigrations(ctx context.Context, driver db.Driver, dbType string, opts ApplyOptions) ([]Result, error) {
+	log := logger(opts.Logger)
+	if err := driver.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer driver.Unlock()
 
-	files, err := filepath.Glob(filepath.Join(migrationsPath, "*_up.sql"))
+	files, err := UpFiles()
 	if err != nil {
-		return fmt.Errorf("failed to list migration files: %w", err)
+		return nil, err
 	}
 	if len(files) == 0 {
-		fmt.Println("No migrations found.")
-		return nil
+		log.Info("No migrations found")
+		return nil, nil
 	}
 
-	sort.Strings(files)
-	var pending []string
-	for _, f := range files {
-		_, name := filepath.Split(f)
-		if !applied[name] {
-			pending = append(pending, f)
-		}
+	applied, baselines, err := appliedMigrations(ctx, driver, files)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordBaselines(ctx, driver, log, baselines); err != nil {
+		return nil, err
 	}
 
+	pending := pendingFiles(files, applied)
+
 	if len(pending) == 0 {
-		fmt.Println("No new migrations to apply.")
-		return nil
+		log.Info("No new migrations to apply")
+		return nil, nil
 	}
 
-	for _, file := range pending {
-		fmt.Printf("Applying migration: %s\n", filepath.Base(file))
-		if err := driver.ExecuteMigration(file); err != nil {
-			return fmt.Errorf("error applying %s: %w", file, err)
+	if late, latest := outOfOrder(pending, applied); len(late) > 0 {
+		if !opts.AllowOutOfOrder {
+			return nil, fmt.Errorf("found %d pending migration(s) older than the latest applied migration %s:\n  %s\nrerun with --allow-out-of-order to apply them anyway",
+				len(late), latest, strings.Join(late, "\n  "))
 		}
+		log.Warn("Applying out-of-order migrations", "count", len(late), "latest", latest)
 	}
 
-	fmt.Printf("Applied %d migration(s).\n", len(pending))
-	return nil
+	// expand every migration first, an undefined variable shouldn't be
+	// found halfway through the run
+	contents := make([]string, len(pending))
+	for i, file := range pending {
+		if contents[i], err = readMigration(file, opts.Vars); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]Result, len(pending))
+	for i, file := range pending {
+		results[i] = Result{ID: strings.TrimSuffix(filepath.Base(file), ".sql"), Status: Skipped}
+	}
+
+	fire := func(event hooks.Event, id, status string, err error) error {
+		return opts.Hooks.run(ctx, driver, log, opts.Vars, hooks.Info{Event: event, Direction: "up", Migration: id, Status: status, Err: err})
+	}
+	fail := func(id string, err error) error {
+		fire(hooks.OnError, id, Failed, err)
+		return err
+	}
+
+	if err := fire(hooks.BeforeRun, "", "", nil); err != nil {
+		return results, fail("", err)
+	}
+
+	for i, file := range pending {
+		id := results[i].ID
+		if err := fire(hooks.BeforeEach, id, "", nil); err != nil {
+			return results, fail(id, err)
+		}
+		log.Debug("Applying migration", "migration", id)
+		start := time.Now()
+		err := retry.Do(ctx, retryPolicy(opts.Retry, contents[i]), log, id, db.IsTransient, func() error {
+			return driver.ApplyMigration(ctx, id, contents[i])
+		})
+		results[i].Duration = time.Since(start)
+		if err != nil {
+			results[i].Status, results[i].Err = Failed, err
+			return results, fail(id, fmt.Errorf("error applying %s: %w", file, err))
+		}
+		results[i].Status = Applied
+		log.Info("Applied migration", "migration", id, "duration", results[i].Duration)
+		if err := fire(hooks.AfterEach, id, Applied, nil); err != nil {
+			return results, fail(id, err)
+		}
+	}
+
+	if err := fire(hooks.AfterRun, "", Applied, nil); err != nil {
+		return results, fail("", err)
+	}
+	log.Info("Applied migrations", "count", len(pending))
+	return results, nil
 }
 
-func RollbackMigrations(driver db.Driver, n int) error {
-	appliedMigrations, err := driver.GetAppliedMigrationsList()
+func RollbackMigrations(ctx context.Context, driver db.Driver, n int, opts RollbackOptions) ([]Result, error) {
+	log := logger(opts.Logger)
+	if err := driver.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer driver.Unlock()
+
+	appliedMigrations, err := driver.GetAppliedMigrationsList(ctx)
 	if err != nil {
-		return fmt.Errorf("could not get applied migrations: %w", err)
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
 	}
 
 	total := len(appliedMigrations)
 	if total == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// ensure that n will always be capped to total
@@ -97,19 +207,126 @@ func RollbackMigrations(driver db.Driver, n int) error {
 	}
 
 	toRollback := appliedMigrations[total-n:]
-	for i := len(toRollback) - 1; i >= 0; i-- {
-		name := toRollback[i] + ".sql"
-		downFile := downFileName(name)
-		path := filepath.Join(migrationsPath, downFile)
-
-		fmt.Printf("Rolling back migration: %s\n", name)
-		if err := driver.RollbackMigration(path, name); err != nil {
-			return fmt.Errorf("failed to rollback %s: %w", name, err)
+
+	// check the whole batch first, so an irreversible migration in the
+	// middle doesn't leave the database half rolled back
+	for _, id := range toRollback {
+		ok, err := irreversible(filepath.Join(migrationsPath, id+".sql"))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, fmt.Errorf("cannot rollback %s: the migration is marked irreversible", id)
 		}
-		fmt.Printf("Rolled back: %s\n", name)
 	}
-	fmt.Printf("Rolled back %d migration(s).\n", n)
-	return nil
+
+	// results are listed in rollback order, newest first
+	results := make([]Result, n)
+	contents := make([]string, n)
+	for i := range results {
+		id := toRollback[n-1-i]
+		results[i] = Result{ID: id, Status: Skipped}
+		path := filepath.Join(migrationsPath, downFileName(id+".sql"))
+		if contents[i], err = readMigration(path, opts.Vars); err != nil {
+			return nil, err
+		}
+	}
+
+	fire := func(event hooks.Event, id, status string, err error) error {
+		return opts.Hooks.run(ctx, driver, log, opts.Vars, hooks.Info{Event: event, Direction: "down", Migration: id, Status: status, Err: err})
+	}
+	fail := func(id string, err error) error {
+		fire(hooks.OnError, id, Failed, err)
+		return err
+	}
+
+	if err := fire(hooks.BeforeRun, "", "", nil); err != nil {
+		return results, fail("", err)
+	}
+
+	for i := range results {
+		id := results[i].ID
+		if err := fire(hooks.BeforeEach, id, "", nil); err != nil {
+			return results, fail(id, err)
+		}
+
+		log.Debug("Rolling back migration", "migration", id)
+		start := time.Now()
+		err := retry.Do(ctx, retryPolicy(opts.Retry, contents[i]), log, id, db.IsTransient, func() error {
+			return driver.RevertMigration(ctx, id, contents[i])
+		})
+		results[i].Duration = time.Since(start)
+		if err != nil {
+			results[i].Status, results[i].Err = Failed, err
+			return results, fail(id, fmt.Errorf("failed to rollback %s: %w", id+".sql", err))
+		}
+		results[i].Status = RolledBack
+		log.Info("Rolled back migration", "migration", id, "duration", results[i].Duration)
+		if err := fire(hooks.AfterEach, id, RolledBack, nil); err != nil {
+			return results, fail(id, err)
+		}
+	}
+
+	if err := fire(hooks.AfterRun, "", RolledBack, nil); err != nil {
+		return results, fail("", err)
+	}
+	log.Info("Rolled back migrations", "count", n)
+	return results, nil
+}
+
+// retryPolicy doesn't retry migrations run outside of a transaction: a
+// failure can leave part of one applied, and running it again on top of
+// that isn't safe.
+func retryPolicy(policy retry.Policy, content string) retry.Policy {
+	if sqlscript.HasAnnotation(content, "no-transaction") {
+		return retry.Policy{}
+	}
+	return policy
+}
+
+// readMigration reads a migration and expands its ${name} placeholders. The
+// file itself is never rewritten, only the SQL sent to the database.
+func readMigration(path string, vars func(string) (string, bool)) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading file %s: %w", path, err)
+	}
+	content, err := sqlscript.Expand(string(data), vars)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return content, nil
+}
+
+func pendingFiles(files []string, applied map[string]bool) []string {
+	sortMigrations(files)
+	var pending []string
+	for _, f := range files {
+		_, name := filepath.Split(f)
+		if !applied[strings.TrimSuffix(name, ".sql")] {
+			pending = append(pending, f)
+		}
+	}
+	return pending
+}
+
+// outOfOrder returns the pending files that sort before the latest applied
+// migration, along with that migration's ID.
+func outOfOrder(pending []string, applied map[string]bool) ([]string, string) {
+	latest := ""
+	for id := range applied {
+		if applied[id] && (latest == "" || Less(latest, id)) {
+			latest = id
+		}
+	}
+
+	var late []string
+	for _, f := range pending {
+		if latest != "" && Less(strings.TrimSuffix(filepath.Base(f), ".sql"), latest) {
+			late = append(late, f)
+		}
+	}
+	return late, latest
 }
 
 func downFileName(upFile string) string {
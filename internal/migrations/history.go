@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+// TableHistory is a best effort guess based on the statements naming each table,
+// dynamic SQL and deleted migration files are not accounted for.
+func TableHistory(ctx context.Context, driver db.Driver, tables []string) (map[string][]string, error) {
+	applied, err := driver.GetAppliedMigrationsList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+
+	patterns := make(map[string]*regexp.Regexp, len(tables))
+	for _, table := range tables {
+		patterns[table] = tablePattern(table)
+	}
+
+	history := make(map[string][]string, len(tables))
+	for _, id := range applied {
+		data, err := os.ReadFile(filepath.Join(migrationsPath, id+".sql"))
+		if err != nil {
+			continue
+		}
+		for table, pattern := range patterns {
+			if pattern.Match(data) {
+				history[table] = append(history[table], id)
+			}
+		}
+	}
+	return history, nil
+}
+
+// tablePattern matches the statements naming a table by its full name, as
+// db.Table.FullName returns it: tables outside the default schema have to be
+// qualified, the others may be.
+func tablePattern(table string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b(?:TABLE(?:\s+IF(?:\s+NOT)?\s+EXISTS)?|ON|INTO|UPDATE|FROM|REFERENCES|JOIN)\s+` +
+		tableReference(table) + `(?:[\s(;,]|$)`)
+}
+
+func tableReference(table string) string {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		schema, name = db.DefaultSchema, table
+	}
+	prefix := quotable(schema) + `\.`
+	if !ok {
+		prefix = `(?:` + prefix + `)?`
+	}
+	return prefix + quotable(name)
+}
+
+func quotable(ident string) string {
+	return `["` + "`" + `]?` + regexp.QuoteMeta(ident) + `["` + "`" + `]?`
+}
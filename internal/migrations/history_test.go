@@ -0,0 +1,28 @@
+package migrations
+
+import "testing"
+
+func TestTablePattern(t *testing.T) {
+	tests := []struct {
+		table string
+		sql   string
+		want  bool
+	}{
+		{"users", "CREATE TABLE users (id integer);", true},
+		{"users", "CREATE TABLE IF NOT EXISTS \"users\" (id integer);", true},
+		{"users", "ALTER TABLE public.users ADD COLUMN name text;", true},
+		{"users", "INSERT INTO \"public\".\"users\" (id) VALUES (1);", true},
+		{"users", "CREATE TABLE billing.users (id integer);", false},
+		{"users", "CREATE TABLE users_archive (id integer);", false},
+		{"billing.users", "CREATE TABLE billing.users (id integer);", true},
+		{"billing.users", "CREATE INDEX users_id ON \"billing\".\"users\" (id);", true},
+		{"billing.users", "CREATE TABLE users (id integer);", false},
+		{"billing.users", "CREATE TABLE public.users (id integer);", false},
+	}
+
+	for _, tt := range tests {
+		if got := tablePattern(tt.table).MatchString(tt.sql); got != tt.want {
+			t.Errorf("tablePattern(%q).MatchString(%q) = %v, want %v", tt.table, tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/jxdones/vagabond/hooks"
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+const (
+	HookAbort = "abort" // a failing hook stops the run
+	HookWarn  = "warn"  // a failing hook is logged and the run goes on
+)
+
+// Hooks runs, for each event, the SQL files in migrations/hooks named after
+// it, then the shell commands from the config, then the Go callbacks
+// registered with the hooks package.
+type Hooks struct {
+	Commands map[string][]string
+	Policy   string // HookAbort or HookWarn, abort when empty
+	Database string // passed on to the hooks to tell fleet targets apart
+}
+
+func (h Hooks) run(ctx context.Context, driver db.Driver, log *slog.Logger, vars func(string) (string, bool), info hooks.Info) error {
+	info.Database = h.Database
+
+	// SQL hooks see the event as ${vagabond_*} variables on top of the
+	// variables of the migrations
+	builtin := map[string]string{
+		"vagabond_event":     string(info.Event),
+		"vagabond_database":  info.Database,
+		"vagabond_direction": info.Direction,
+		"vagabond_migration": info.Migration,
+		"vagabond_status":    info.Status,
+	}
+	lookup := func(name string) (string, bool) {
+		if value, ok := builtin[name]; ok {
+			return value, true
+		}
+		if vars == nil {
+			return "", false
+		}
+		return vars(name)
+	}
+
+	files, err := filepath.Glob(filepath.Join(migrationsPath, "hooks", string(info.Event)+"*.sql"))
+	if err != nil {
+		return fmt.Errorf("failed to list hook files: %w", err)
+	}
+	sort.Strings(files)
+
+	type hook struct {
+		name string
+		run  func() error
+	}
+	var all []hook
+	for _, file := range files {
+		all = append(all, hook{file, func() error {
+			content, err := readMigration(file, lookup)
+			if err != nil {
+				return err
+			}
+			return driver.Exec(ctx, content)
+		}})
+	}
+	for _, command := range h.Commands[string(info.Event)] {
+		all = append(all, hook{command, func() error { return runCommand(ctx, command, info) }})
+	}
+	for i, fn := range hooks.Registered(info.Event) {
+		all = append(all, hook{fmt.Sprintf("callback %d", i+1), func() error { return fn(ctx, info) }})
+	}
+
+	for _, hook := range all {
+		log.Debug("Running hook", "event", info.Event, "hook", hook.name)
+		err := hook.run()
+		if err == nil {
+			continue
+		}
+		// on-error hooks fire for a run that already failed, their own
+		// failure shouldn't hide the migration error
+		if h.Policy == HookWarn || info.Event == hooks.OnError {
+			log.Warn("Hook failed", "event", info.Event, "hook", hook.name, "error", err)
+			continue
+		}
+		return fmt.Errorf("%s hook %s failed: %w", info.Event, hook.name, err)
+	}
+	return nil
+}
+
+// runCommand runs a shell hook with the event described in VAGABOND_*
+// environment variables. Its output goes to stderr to keep stdout for the
+// command's own output.
+func runCommand(ctx context.Context, command string, info hooks.Info) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"VAGABOND_EVENT="+string(info.Event),
+		"VAGABOND_DATABASE="+info.Database,
+		"VAGABOND_DIRECTION="+info.Direction,
+		"VAGABOND_MIGRATION="+info.Migration,
+		"VAGABOND_STATUS="+info.Status,
+	)
+	if info.Err != nil {
+		cmd.Env = append(cmd.Env, "VAGABOND_ERROR="+info.Err.Error())
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
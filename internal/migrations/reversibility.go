@@ -0,0 +1,204 @@
+package migrations
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+type TestResult struct {
+	ID       string
+	Duration time.Duration
+	Err      error
+	// Diff lists the schema lines that differ after the down migration, as
+	// "-" for lines that were lost and "+" for lines that were left behind.
+	Diff    []string
+	Skipped bool
+}
+
+// TestReversibility runs every migration up, down and up again on a scratch
+// database, using DumpSchema to check that the down migration restores the
+// schema the up migration started from.
+func TestReversibility(ctx context.Context, driver db.Driver, vars func(string) (string, bool)) ([]TestResult, error) {
+	applied, err := driver.GetAppliedMigrationsList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	if len(applied) > 0 {
+		return nil, fmt.Errorf("the database already has %d applied migration(s), use an empty scratch database", len(applied))
+	}
+
+	files, err := UpFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []TestResult
+	broken := false
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".sql")
+		if broken {
+			results = append(results, TestResult{ID: id, Skipped: true})
+			continue
+		}
+
+		start := time.Now()
+		result, ok := roundTrip(ctx, driver, file, vars)
+		result.ID = id
+		result.Duration = time.Since(start)
+		results = append(results, result)
+
+		// later migrations build on this one, so they can't be tested
+		// once it is no longer applied cleanly
+		broken = !ok
+	}
+	return results, nil
+}
+
+// roundTrip reports whether the migration ended up applied, so the next one
+// can be tested on top of it.
+func roundTrip(ctx context.Context, driver db.Driver, upFile string, vars func(string) (string, bool)) (TestResult, bool) {
+	name := filepath.Base(upFile)
+	id := strings.TrimSuffix(name, ".sql")
+	downFile := filepath.Join(migrationsPath, downFileName(name))
+
+	up, err := readMigration(upFile, vars)
+	if err != nil {
+		return TestResult{Err: err}, false
+	}
+
+	before, err := driver.DumpSchema(ctx)
+	if err != nil {
+		return TestResult{Err: fmt.Errorf("failed to dump schema: %w", err)}, false
+	}
+
+	if err := driver.ApplyMigration(ctx, id, up); err != nil {
+		return TestResult{Err: fmt.Errorf("up failed: %w", err)}, false
+	}
+
+	if ok, err := irreversible(upFile); err != nil || ok {
+		return TestResult{Err: err, Skipped: ok}, true
+	}
+
+	if _, err := os.Stat(downFile); err != nil {
+		return TestResult{Err: fmt.Errorf("missing down migration %s", downFile)}, true
+	}
+	down, err := readMigration(downFile, vars)
+	if err != nil {
+		return TestResult{Err: err}, true
+	}
+	if err := driver.RevertMigration(ctx, id, down); err != nil {
+		return TestResult{Err: fmt.Errorf("down failed: %w", err)}, true
+	}
+
+	after, err := driver.DumpSchema(ctx)
+	if err != nil {
+		return TestResult{Err: fmt.Errorf("failed to dump schema: %w", err)}, false
+	}
+
+	var result TestResult
+	if diff := diffLines(before, after); len(diff) > 0 {
+		result = TestResult{Err: fmt.Errorf("schema after down differs from the schema before up"), Diff: diff}
+	}
+
+	if err := driver.ApplyMigration(ctx, id, up); err != nil {
+		if result.Err == nil {
+			result.Err = fmt.Errorf("up failed when re-applied after down: %w", err)
+		}
+		return result, false
+	}
+	return result, true
+}
+
+func diffLines(before, after string) []string {
+	count := map[string]int{}
+	for _, line := range strings.Split(before, "\n") {
+		count[line]++
+	}
+	for _, line := range strings.Split(after, "\n") {
+		count[line]--
+	}
+
+	var diff []string
+	for _, line := range strings.Split(before, "\n") {
+		if count[line] > 0 && strings.TrimSpace(line) != "" {
+			diff = append(diff, "- "+line)
+			count[line]--
+		}
+	}
+	for _, line := range strings.Split(after, "\n") {
+		if count[line] < 0 && strings.TrimSpace(line) != "" {
+			diff = append(diff, "+ "+line)
+			count[line]++
+		}
+	}
+	return diff
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func WriteJUnit(w io.Writer, results []TestResult) error {
+	suite := junitSuite{Name: "vagabond reversibility", Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		tc := junitCase{
+			Name:      result.ID,
+			ClassName: "migrations",
+			Time:      seconds(result.Duration),
+		}
+		switch {
+		case result.Skipped:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+		case result.Err != nil:
+			tc.Failure = &junitFailure{Message: result.Err.Error(), Body: strings.Join(result.Diff, "\n")}
+			suite.Failures++
+		}
+		total += result.Duration
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
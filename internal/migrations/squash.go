@@ -0,0 +1,195 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/sqlscript"
+)
+
+const squashesAnnotation = "-- vagabond:squashes "
+
+// Baseline is the migration Squash replaced older migrations with.
+type Baseline struct {
+	ID       string
+	Squashed []string
+}
+
+// Squash replaces the migrations with a version before the given one by a
+// baseline migration holding the schema they build. They are applied to
+// scratch, an empty database of the same kind as the real ones, and its
+// schema is dumped. The baseline lists the IDs it replaces so that
+// databases which applied all of them record it instead of running it.
+func Squash(ctx context.Context, scratch db.Driver, before string, vars func(string) (string, bool)) (Baseline, error) {
+	if before == "" || Version(before) != before {
+		return Baseline{}, fmt.Errorf("invalid version %q", before)
+	}
+
+	files, err := UpFiles()
+	if err != nil {
+		return Baseline{}, err
+	}
+	var squashed []string
+	for _, file := range files {
+		if Less(file, before) {
+			squashed = append(squashed, file)
+		}
+	}
+	if len(squashed) < 2 {
+		return Baseline{}, fmt.Errorf("found %d migration(s) before %s, nothing to squash", len(squashed), before)
+	}
+
+	applied, err := scratch.GetAppliedMigrations(ctx)
+	if err != nil {
+		return Baseline{}, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	if len(applied) > 0 {
+		return Baseline{}, fmt.Errorf("the scratch database already has %d migration(s) applied, squash needs an empty one", len(applied))
+	}
+
+	baseline := Baseline{}
+	for _, file := range squashed {
+		id := strings.TrimSuffix(filepath.Base(file), ".sql")
+		content, err := readMigration(file, vars)
+		if err != nil {
+			return Baseline{}, err
+		}
+		slog.Debug("Applying migration", "migration", id)
+		if err := scratch.ApplyMigration(ctx, id, content); err != nil {
+			return Baseline{}, fmt.Errorf("error applying %s: %w", file, err)
+		}
+		baseline.Squashed = append(baseline.Squashed, id)
+	}
+
+	dump, err := scratch.DumpSchema(ctx)
+	if err != nil {
+		return Baseline{}, fmt.Errorf("failed to dump the schema: %w", err)
+	}
+
+	// the baseline takes the version of the last squashed migration: there
+	// may be no free version between it and the next one, and its file is
+	// removed so the version stays unique. Existing databases still list
+	// the squashed IDs, appliedMigrations counts the baseline as applied
+	// there before pending and out-of-order migrations are worked out.
+	version := Version(squashed[len(squashed)-1])
+	baseline.ID = version + "_baseline_up"
+
+	var up strings.Builder
+	up.WriteString("-- Schema built by the migrations below, which this baseline replaces.\n")
+	up.WriteString("-- Databases that applied all of them record it without running it.\n")
+	for _, id := range baseline.Squashed {
+		up.WriteString(squashesAnnotation + id + "\n")
+	}
+	up.WriteString("\n" + schemaStatements(dump))
+
+	if err := CreateMigrationWithContent(version, "baseline", up.String(), "-- vagabond:irreversible\n"); err != nil {
+		return Baseline{}, err
+	}
+	for _, file := range squashed {
+		for _, path := range []string{file, filepath.Join(migrationsPath, downFileName(filepath.Base(file)))} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return Baseline{}, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+	}
+	return baseline, nil
+}
+
+// schemaStatements keeps the statements of a schema dump as written, minus
+// its comments and vagabond's own bookkeeping, which every database creates
+// for itself.
+func schemaStatements(dump string) string {
+	var b strings.Builder
+	for _, stmt := range sqlscript.Split(dump) {
+		if !strings.Contains(stmt.Text, "vagabond_migrations") {
+			b.WriteString(stmt.Raw + ";\n\n")
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// squashedIDs returns the IDs a baseline migration replaces, none for
+// other migrations.
+func squashedIDs(content string) []string {
+	var ids []string
+	for _, line := range strings.Split(content, "\n") {
+		if id, ok := strings.CutPrefix(strings.TrimSpace(line), squashesAnnotation); ok {
+			ids = append(ids, strings.TrimSpace(id))
+		}
+	}
+	return ids
+}
+
+// pendingBaseline is an unrecorded baseline some of whose squashed
+// migrations were applied.
+type pendingBaseline struct {
+	ID       string
+	Squashed int
+	Applied  int
+}
+
+func (b pendingBaseline) complete() bool {
+	return b.Applied == b.Squashed
+}
+
+// appliedMigrations returns the migrations applied on the database. A
+// baseline whose squashed migrations were all applied counts as applied:
+// the database has its schema, the baseline only needs recording. Such
+// baselines are returned along with the ones only partly applied, which
+// the database can't use.
+func appliedMigrations(ctx context.Context, driver db.Driver, files []string) (map[string]bool, []pendingBaseline, error) {
+	applied, err := driver.GetAppliedMigrations(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+
+	var baselines []pendingBaseline
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".sql")
+		if applied[id] {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading file %s: %w", file, err)
+		}
+		ids := squashedIDs(string(data))
+		b := pendingBaseline{ID: id, Squashed: len(ids)}
+		for _, squashed := range ids {
+			if applied[squashed] {
+				b.Applied++
+			}
+		}
+		if b.Applied == 0 {
+			continue
+		}
+		baselines = append(baselines, b)
+		if b.complete() {
+			applied[id] = true
+		}
+	}
+	return applied, baselines, nil
+}
+
+// recordBaselines records the baselines appliedMigrations recognized. A
+// database with only some of a baseline's migrations applied has to catch
+// up with the migrations from before the squash first.
+func recordBaselines(ctx context.Context, driver db.Driver, log *slog.Logger, baselines []pendingBaseline) error {
+	for _, b := range baselines {
+		if !b.complete() {
+			return fmt.Errorf("%s replaces %d migrations but only %d are applied, apply the rest with the migrations from before the squash first", b.ID, b.Squashed, b.Applied)
+		}
+	}
+	for _, b := range baselines {
+		if err := driver.RecordMigration(ctx, b.ID); err != nil {
+			return fmt.Errorf("failed to record %s: %w", b.ID, err)
+		}
+		log.Info("Recorded baseline", "migration", b.ID, "squashed", b.Squashed)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+type MigrationStatus struct {
+	ID      string
+	Applied bool
+	// OutOfOrder marks a pending migration older than the latest applied one.
+	OutOfOrder   bool
+	Irreversible bool
+}
+
+// Status lists every migration. A baseline is applied once all the
+// migrations it squashed are, even before pack records it.
+func Status(ctx context.Context, driver db.Driver) ([]MigrationStatus, error) {
+	files, err := UpFiles()
+	if err != nil {
+		return nil, err
+	}
+	applied, _, err := appliedMigrations(ctx, driver, files)
+	if err != nil {
+		return nil, err
+	}
+
+	late, _ := outOfOrder(pendingFiles(files, applied), applied)
+
+	var statuses []MigrationStatus
+	for _, f := range files {
+		id := strings.TrimSuffix(filepath.Base(f), ".sql")
+		irreversible, err := irreversible(f)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, MigrationStatus{
+			ID:           id,
+			Applied:      applied[id],
+			OutOfOrder:   slices.Contains(late, f),
+			Irreversible: irreversible,
+		})
+	}
+	return statuses, nil
+}
+
+func PendingCount(ctx context.Context, driver db.Driver) (int, error) {
+	statuses, err := Status(ctx, driver)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func UpFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(migrationsPath, "*_up.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files: %w", err)
+	}
+	sortMigrations(files)
+	return files, nil
+}
+
+func PendingFiles(ctx context.Context, driver db.Driver) ([]string, error) {
+	files, err := UpFiles()
+	if err != nil {
+		return nil, err
+	}
+	applied, _, err := appliedMigrations(ctx, driver, files)
+	if err != nil {
+		return nil, err
+	}
+	return pendingFiles(files, applied), nil
+}
@@ -0,0 +1,148 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is what migration templates are rendered with.
+type TemplateData struct {
+	Name    string   // the migration name
+	Table   string   // --table, the migration name when not given
+	Columns []string // --columns
+	Index   string   // --index, derived from the table and columns when not given
+	Dialect string
+}
+
+type migrationTemplate struct {
+	up, down string
+}
+
+// builtinTemplates are used when migrations/templates has no template of
+// the same name.
+var builtinTemplates = map[string]map[string]migrationTemplate{
+	"postgres": {
+		"add_table": {
+			up: `CREATE TABLE {{ .Table }} (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`,
+			down: "DROP TABLE {{ .Table }};\n",
+		},
+		"add_index": {
+			up: `-- vagabond:no-transaction
+CREATE INDEX CONCURRENTLY IF NOT EXISTS {{ .Index }} ON {{ .Table }} ({{ join .Columns ", " }});
+`,
+			down: `-- vagabond:no-transaction
+DROP INDEX CONCURRENTLY IF EXISTS {{ .Index }};
+`,
+		},
+	},
+	"sqlite": {
+		"add_table": {
+			up: `CREATE TABLE {{ .Table }} (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`,
+			down: "DROP TABLE {{ .Table }};\n",
+		},
+		"add_index": {
+			up:   "CREATE INDEX IF NOT EXISTS {{ .Index }} ON {{ .Table }} ({{ join .Columns \", \" }});\n",
+			down: "DROP INDEX IF EXISTS {{ .Index }};\n",
+		},
+	},
+}
+
+var templateFuncs = template.FuncMap{"join": strings.Join}
+
+// Templates lists the templates available for dialect: the project's own
+// and the built-in ones.
+func Templates(dialect string) ([]string, error) {
+	names := map[string]bool{}
+	for name := range builtinTemplates[dialect] {
+		names[name] = true
+	}
+
+	files, err := filepath.Glob(filepath.Join(migrationsPath, "templates", "*_up.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	for _, file := range files {
+		names[strings.TrimSuffix(filepath.Base(file), "_up.sql")] = true
+	}
+
+	var list []string
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// RenderTemplate renders the up and down migrations of the named template.
+// The project's migrations/templates/<name>_up.sql and <name>_down.sql take
+// precedence over the built-in template for the dialect.
+func RenderTemplate(name string, data TemplateData) (string, string, error) {
+	if data.Table == "" {
+		data.Table = data.Name
+	}
+	if data.Index == "" {
+		data.Index = strings.Join(append([]string{data.Table}, data.Columns...), "_") + "_idx"
+	}
+
+	source, err := loadTemplate(name, data.Dialect)
+	if err != nil {
+		return "", "", err
+	}
+
+	up, err := render(name+"_up", source.up, data)
+	if err != nil {
+		return "", "", err
+	}
+	down, err := render(name+"_down", source.down, data)
+	if err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+func loadTemplate(name, dialect string) (migrationTemplate, error) {
+	dir := filepath.Join(migrationsPath, "templates")
+	up, err := os.ReadFile(filepath.Join(dir, name+"_up.sql"))
+	if errors.Is(err, os.ErrNotExist) {
+		if builtin, ok := builtinTemplates[dialect][name]; ok {
+			return builtin, nil
+		}
+		available, _ := Templates(dialect)
+		return migrationTemplate{}, fmt.Errorf("unknown template %q for %s, available: %s", name, dialect, strings.Join(available, ", "))
+	}
+	if err != nil {
+		return migrationTemplate{}, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	// a template without a down file scaffolds an empty down migration
+	down, err := os.ReadFile(filepath.Join(dir, name+"_down.sql"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return migrationTemplate{}, fmt.Errorf("failed to read template: %w", err)
+	}
+	return migrationTemplate{up: string(up), down: string(down)}, nil
+}
+
+func render(name, source string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,122 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     TemplateData
+		up       string
+		down     string
+	}{
+		{
+			name:     "postgres add_table defaults to the migration name",
+			template: "add_table",
+			data:     TemplateData{Name: "users", Dialect: "postgres"},
+			up:       "CREATE TABLE users (\n    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,\n    created_at TIMESTAMPTZ NOT NULL DEFAULT now()\n);\n",
+			down:     "DROP TABLE users;\n",
+		},
+		{
+			name:     "sqlite add_table",
+			template: "add_table",
+			data:     TemplateData{Name: "create_users", Table: "users", Dialect: "sqlite"},
+			up:       "CREATE TABLE users (\n    id INTEGER PRIMARY KEY AUTOINCREMENT,\n    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP\n);\n",
+			down:     "DROP TABLE users;\n",
+		},
+		{
+			name:     "postgres add_index derives the index name",
+			template: "add_index",
+			data:     TemplateData{Name: "index_users", Table: "users", Columns: []string{"email", "name"}, Dialect: "postgres"},
+			up:       "-- vagabond:no-transaction\nCREATE INDEX CONCURRENTLY IF NOT EXISTS users_email_name_idx ON users (email, name);\n",
+			down:     "-- vagabond:no-transaction\nDROP INDEX CONCURRENTLY IF EXISTS users_email_name_idx;\n",
+		},
+		{
+			name:     "sqlite add_index with a given index name",
+			template: "add_index",
+			data:     TemplateData{Name: "index_users", Table: "users", Columns: []string{"email"}, Index: "users_by_email", Dialect: "sqlite"},
+			up:       "CREATE INDEX IF NOT EXISTS users_by_email ON users (email);\n",
+			down:     "DROP INDEX IF EXISTS users_by_email;\n",
+		},
+	}
+
+	t.Chdir(t.TempDir())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down, err := RenderTemplate(tt.template, tt.data)
+			if err != nil {
+				t.Fatalf("RenderTemplate() error = %v", err)
+			}
+			if up != tt.up {
+				t.Errorf("up = %q, want %q", up, tt.up)
+			}
+			if down != tt.down {
+				t.Errorf("down = %q, want %q", down, tt.down)
+			}
+		})
+	}
+}
+
+func TestProjectTemplates(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := filepath.Join(migrationsPath, "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"add_table_up.sql":   "CREATE TABLE {{ .Table }} (id uuid PRIMARY KEY);\n",
+		"add_table_down.sql": "DROP TABLE {{ .Table }};\n",
+		"seed_up.sql":        "INSERT INTO {{ .Table }} DEFAULT VALUES;\n",
+		"broken_up.sql":      "SELECT {{ .Missing }};\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := Templates("sqlite")
+	if err != nil {
+		t.Fatalf("Templates() error = %v", err)
+	}
+	if want := []string{"add_index", "add_table", "broken", "seed"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Templates() = %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		up       string
+		down     string
+		wantErr  string
+	}{
+		{name: "overrides the built-in template", template: "add_table", up: "CREATE TABLE users (id uuid PRIMARY KEY);\n", down: "DROP TABLE users;\n"},
+		{name: "without a down file", template: "seed", up: "INSERT INTO users DEFAULT VALUES;\n", down: ""},
+		{name: "built-in template", template: "add_index", up: "CREATE INDEX IF NOT EXISTS users_email_idx ON users (email);\n", down: "DROP INDEX IF EXISTS users_email_idx;\n"},
+		{name: "unknown field", template: "broken", wantErr: "failed to render template broken_up"},
+		{name: "unknown template", template: "nope", wantErr: `unknown template "nope" for sqlite, available: add_index, add_table, broken, seed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down, err := RenderTemplate(tt.template, TemplateData{Name: "users", Columns: []string{"email"}, Dialect: "sqlite"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RenderTemplate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderTemplate() error = %v", err)
+			}
+			if up != tt.up || down != tt.down {
+				t.Errorf("RenderTemplate() = %q, %q, want %q, %q", up, down, tt.up, tt.down)
+			}
+		})
+	}
+}
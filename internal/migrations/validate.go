@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/sqlscript"
+)
+
+type Problem struct {
+	File    string
+	Message string
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+?)_(up|down)\.sql$`)
+
+// sketch writes its dumps into the migrations directory by default, so they
+// are not stray files.
+var schemaDumps = map[string]bool{"schema.sql": true, "schema.json": true, "schema.yaml": true}
+
+// Validate checks the whole migrations directory and returns every problem
+// found instead of stopping at the first one.
+func Validate() ([]Problem, error) {
+	entries, err := os.ReadDir(migrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	var problems []Problem
+	report := func(file, format string, args ...any) {
+		problems = append(problems, Problem{File: file, Message: fmt.Sprintf(format, args...)})
+	}
+
+	ups := map[string]bool{}
+	downs := map[string]bool{}
+	irreversibles := map[string]bool{}
+	names := map[string][]string{}
+	blank := map[string]string{}
+	for _, entry := range entries {
+		file := entry.Name()
+		if entry.IsDir() || schemaDumps[file] {
+			continue
+		}
+
+		match := fileNamePattern.FindStringSubmatch(file)
+		if match == nil {
+			report(file, "does not follow the <version>_<name>_(up|down).sql naming convention")
+			continue
+		}
+
+		version, name, direction := match[1], match[2], match[3]
+		id := version + "_" + name
+		if direction == "up" {
+			ups[id] = true
+		} else {
+			downs[id] = true
+		}
+		if !slices.Contains(names[version], name) {
+			names[version] = append(names[version], name)
+		}
+
+		data, err := os.ReadFile(filepath.Join(migrationsPath, file))
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %s: %w", file, err)
+		}
+		if IsIrreversible(string(data)) {
+			irreversibles[id] = true
+		}
+		if strings.TrimSpace(string(data)) == "" {
+			blank[file] = "is empty"
+		} else if len(sqlscript.Split(string(data))) == 0 {
+			blank[file] = "only contains comments"
+		}
+	}
+
+	// an irreversible migration has nothing to run on the way down
+	for file, message := range blank {
+		if !strings.HasSuffix(file, "_down.sql") || !irreversibles[strings.TrimSuffix(file, "_down.sql")] {
+			report(file, "%s", message)
+		}
+	}
+	for id := range ups {
+		if !downs[id] && !irreversibles[id] {
+			report(id+"_up.sql", "has no matching %s_down.sql", id)
+		}
+	}
+	for id := range downs {
+		if !ups[id] {
+			report(id+"_down.sql", "has no matching %s_up.sql", id)
+		}
+	}
+	for version, list := range names {
+		if len(list) > 1 {
+			sort.Strings(list)
+			report(version+"_*", "version is used by more than one migration: %s", strings.Join(list, ", "))
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].File < problems[j].File })
+	return problems, nil
+}
@@ -0,0 +1,118 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const sql = "SELECT 1;\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []Problem
+	}{
+		{
+			name: "valid",
+			files: map[string]string{
+				"0001_users_up.sql":   sql,
+				"0001_users_down.sql": sql,
+				"schema.sql":          "",
+			},
+		},
+		{
+			name: "missing down file",
+			files: map[string]string{
+				"0001_users_up.sql": sql,
+			},
+			want: []Problem{{File: "0001_users_up.sql", Message: "has no matching 0001_users_down.sql"}},
+		},
+		{
+			name: "missing up file",
+			files: map[string]string{
+				"0001_users_down.sql": sql,
+			},
+			want: []Problem{{File: "0001_users_down.sql", Message: "has no matching 0001_users_up.sql"}},
+		},
+		{
+			name: "irreversible migration without a down file",
+			files: map[string]string{
+				"0001_users_up.sql": "-- vagabond:irreversible\n" + sql,
+			},
+		},
+		{
+			name: "bad names",
+			files: map[string]string{
+				"users_up.sql":         sql,
+				"0001_users.sql":       sql,
+				"0001-users_up.sql":    sql,
+				"0002_users_up.sql.bk": sql,
+			},
+			want: []Problem{
+				{File: "0001-users_up.sql", Message: "does not follow the <version>_<name>_(up|down).sql naming convention"},
+				{File: "0001_users.sql", Message: "does not follow the <version>_<name>_(up|down).sql naming convention"},
+				{File: "0002_users_up.sql.bk", Message: "does not follow the <version>_<name>_(up|down).sql naming convention"},
+				{File: "users_up.sql", Message: "does not follow the <version>_<name>_(up|down).sql naming convention"},
+			},
+		},
+		{
+			name: "duplicate versions",
+			files: map[string]string{
+				"0001_users_up.sql":   sql,
+				"0001_users_down.sql": sql,
+				"0001_posts_up.sql":   sql,
+				"0001_posts_down.sql": sql,
+			},
+			want: []Problem{{File: "0001_*", Message: "version is used by more than one migration: posts, users"}},
+		},
+		{
+			name: "empty and comment only files",
+			files: map[string]string{
+				"0001_users_up.sql":   "  \n",
+				"0001_users_down.sql": "-- 0001_users_down.sql\n/* nothing */\n",
+			},
+			want: []Problem{
+				{File: "0001_users_down.sql", Message: "only contains comments"},
+				{File: "0001_users_up.sql", Message: "is empty"},
+			},
+		},
+		{
+			name: "empty down file of an irreversible migration",
+			files: map[string]string{
+				"0001_users_up.sql":   "-- vagabond:irreversible\n" + sql,
+				"0001_users_down.sql": "-- nothing to undo\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := os.MkdirAll(filepath.Join(migrationsPath, "templates"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(migrationsPath, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			problems, err := Validate()
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !reflect.DeepEqual(problems, tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMissingDirectory(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if _, err := Validate(); err == nil {
+		t.Error("Validate() without a migrations directory succeeded, want an error")
+	}
+}
@@ -0,0 +1,166 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Versioning schemes for new migrations.
+const (
+	Timestamp  = "timestamp"  // 20240117093012_add_users
+	Sequential = "sequential" // 0042_add_users
+)
+
+const (
+	timestampLayout = "20060102150405"
+	sequentialWidth = 4
+)
+
+// Version returns the leading number of a migration ID or file name.
+func Version(name string) string {
+	name = filepath.Base(name)
+	end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		return name
+	}
+	return name[:end]
+}
+
+func isTimestamp(version string) bool {
+	return len(version) == len(timestampLayout)
+}
+
+// Less orders migration IDs or files by version. Sequential versions come
+// after every timestamp, so a history started with timestamps can go on
+// with sequential numbers.
+func Less(a, b string) bool {
+	a, b = filepath.Base(a), filepath.Base(b)
+	va, vb := Version(a), Version(b)
+	if ta, tb := isTimestamp(va), isTimestamp(vb); ta != tb {
+		return ta
+	}
+	na, errA := strconv.ParseUint(va, 10, 64)
+	nb, errB := strconv.ParseUint(vb, 10, 64)
+	if errA == nil && errB == nil && na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+func sortMigrations(files []string) {
+	sort.SliceStable(files, func(i, j int) bool { return Less(files[i], files[j]) })
+}
+
+// NextVersion returns the version of a new migration: the current time, or
+// for the sequential scheme one more than the highest sequential version in
+// the migrations directory.
+func NextVersion(scheme string) (string, error) {
+	switch scheme {
+	case "", Timestamp:
+		return time.Now().Format(timestampLayout), nil
+	case Sequential:
+		last, err := lastSequential()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%0*d", sequentialWidth, last+1), nil
+	default:
+		return "", fmt.Errorf("unsupported versioning %q: use timestamp or sequential", scheme)
+	}
+}
+
+func lastSequential() (uint64, error) {
+	files, err := filepath.Glob(filepath.Join(migrationsPath, "*.sql"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list migration files: %w", err)
+	}
+
+	var last uint64
+	for _, file := range files {
+		version := Version(file)
+		if version == "" || isTimestamp(version) {
+			continue
+		}
+		if n, err := strconv.ParseUint(version, 10, 64); err == nil && n > last {
+			last = n
+		}
+	}
+	return last, nil
+}
+
+// Rename is a migration moved to a new version by Renumber.
+type Rename struct {
+	From, To string
+}
+
+// Renumber gives the timestamped migrations the next sequential versions,
+// in order, renaming both files and the file name comment at their top.
+// Migrations in keep, usually the ones already applied somewhere, keep
+// their timestamp. With dryRun nothing is renamed.
+func Renumber(keep map[string]bool, dryRun bool) ([]Rename, error) {
+	files, err := UpFiles()
+	if err != nil {
+		return nil, err
+	}
+	last, err := lastSequential()
+	if err != nil {
+		return nil, err
+	}
+
+	var renames []Rename
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".sql")
+		version := Version(id)
+		if !isTimestamp(version) || keep[id] {
+			continue
+		}
+		last++
+		to := fmt.Sprintf("%0*d", sequentialWidth, last) + strings.TrimPrefix(id, version)
+		renames = append(renames, Rename{From: id, To: to})
+	}
+
+	if dryRun {
+		return renames, nil
+	}
+	for i, rename := range renames {
+		up, down := rename.From+".sql", downFileName(rename.From+".sql")
+		newUp, newDown := rename.To+".sql", downFileName(rename.To+".sql")
+		for _, pair := range [][2]string{{up, newUp}, {down, newDown}} {
+			if err := renameMigrationFile(pair[0], pair[1]); err != nil {
+				return renames[:i], err
+			}
+		}
+	}
+	return renames, nil
+}
+
+// renameMigrationFile moves a migration file, a missing down file is fine.
+func renameMigrationFile(from, to string) error {
+	fromPath := filepath.Join(migrationsPath, from)
+	toPath := filepath.Join(migrationsPath, to)
+
+	data, err := os.ReadFile(fromPath)
+	if os.IsNotExist(err) && strings.HasSuffix(from, "_down.sql") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", fromPath, err)
+	}
+	if _, err := os.Stat(toPath); err == nil {
+		return fmt.Errorf("cannot rename %s: %s already exists", fromPath, toPath)
+	}
+
+	content := string(data)
+	if header := "-- " + from + "\n"; strings.HasPrefix(content, header) {
+		content = "-- " + to + "\n" + strings.TrimPrefix(content, header)
+	}
+	if err := os.WriteFile(toPath, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("error writing file %s: %w", toPath, err)
+	}
+	return os.Remove(fromPath)
+}
@@ -0,0 +1,171 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"0001_a_up", "0002_b_up", true},
+		{"0002_b_up", "0001_a_up", false},
+		{"0009_a_up", "0010_b_up", true},
+		{"9_a_up", "10_b_up", true},
+		{"20240101000000_a_up", "20240102000000_b_up", true},
+		{"20240101000000_a_up", "0001_b_up", true},
+		{"0001_b_up", "20240101000000_a_up", false},
+		{"0001_a_up", "0001_b_up", true},
+		{"migrations/0002_b_up.sql", "migrations/0010_a_up.sql", true},
+	}
+
+	for _, tt := range tests {
+		if got := Less(tt.a, tt.b); got != tt.want {
+			t.Errorf("Less(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{name: "empty directory", want: "0001"},
+		{name: "timestamps only", files: []string{"20240101000000_a_up.sql"}, want: "0001"},
+		{name: "after the highest sequential version", files: []string{"20240101000000_a_up.sql", "0002_b_up.sql", "0009_c_up.sql", "0009_c_down.sql"}, want: "0010"},
+		{name: "past the width", files: []string{"9999_a_up.sql"}, want: "10000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			writeMigrations(t, tt.files...)
+			got, err := NextVersion(Sequential)
+			if err != nil {
+				t.Fatalf("NextVersion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NextVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NextVersion("semver"); err == nil {
+		t.Error("NextVersion(\"semver\") succeeded, want an error")
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		keep    map[string]bool
+		dryRun  bool
+		renames []Rename
+		after   []string
+	}{
+		{
+			name:  "timestamps after sequential versions",
+			files: []string{"0001_a_up.sql", "0001_a_down.sql", "20240101000000_b_up.sql", "20240101000000_b_down.sql", "20240102000000_c_up.sql"},
+			renames: []Rename{
+				{From: "20240101000000_b_up", To: "0002_b_up"},
+				{From: "20240102000000_c_up", To: "0003_c_up"},
+			},
+			after: []string{"0001_a_down.sql", "0001_a_up.sql", "0002_b_down.sql", "0002_b_up.sql", "0003_c_up.sql"},
+		},
+		{
+			name:  "kept migrations",
+			files: []string{"20240101000000_a_up.sql", "20240102000000_b_up.sql"},
+			keep:  map[string]bool{"20240101000000_a_up": true},
+			renames: []Rename{
+				{From: "20240102000000_b_up", To: "0001_b_up"},
+			},
+			after: []string{"0001_b_up.sql", "20240101000000_a_up.sql"},
+		},
+		{
+			name:   "dry run",
+			files:  []string{"20240101000000_a_up.sql"},
+			dryRun: true,
+			renames: []Rename{
+				{From: "20240101000000_a_up", To: "0001_a_up"},
+			},
+			after: []string{"20240101000000_a_up.sql"},
+		},
+		{
+			name:  "nothing to renumber",
+			files: []string{"0001_a_up.sql"},
+			after: []string{"0001_a_up.sql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			writeMigrations(t, tt.files...)
+
+			renames, err := Renumber(tt.keep, tt.dryRun)
+			if err != nil {
+				t.Fatalf("Renumber() error = %v", err)
+			}
+			if !reflect.DeepEqual(renames, tt.renames) {
+				t.Errorf("Renumber() = %v, want %v", renames, tt.renames)
+			}
+			if got := listMigrations(t); !reflect.DeepEqual(got, tt.after) {
+				t.Errorf("files = %v, want %v", got, tt.after)
+			}
+		})
+	}
+}
+
+func TestRenumberRewritesHeader(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeMigrations(t, "20240101000000_a_up.sql", "20240101000000_a_down.sql")
+
+	if _, err := Renumber(nil, false); err != nil {
+		t.Fatalf("Renumber() error = %v", err)
+	}
+	for _, name := range []string{"0001_a_up.sql", "0001_a_down.sql"} {
+		data, err := os.ReadFile(filepath.Join(migrationsPath, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "-- " + name + "\nSELECT 1;\n"; string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+// writeMigrations creates migration files whose content starts with their
+// file name comment, as vagabond new writes them.
+func writeMigrations(t *testing.T, names ...string) {
+	t.Helper()
+	if err := os.MkdirAll(migrationsPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(migrationsPath, name), []byte("-- "+name+"\nSELECT 1;\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listMigrations(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(migrationsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
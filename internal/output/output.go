@@ -0,0 +1,81 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Version is bumped whenever a field of Document is renamed, removed or
+// changes meaning. New fields can be added without a bump.
+const Version = 1
+
+// Document is the single JSON document printed by --output=json.
+type Document struct {
+	Version    int         `json:"version"`
+	Command    string      `json:"command"`
+	OK         bool        `json:"ok"`
+	Error      string      `json:"error,omitempty"`
+	Migrations []Migration `json:"migrations,omitempty"`
+	Targets    []Target    `json:"targets,omitempty"`
+	Problems   []Problem   `json:"problems,omitempty"`
+	Findings   []Finding   `json:"findings,omitempty"`
+	// Irreversible lists the migrations marked -- vagabond:irreversible.
+	Irreversible []string `json:"irreversible,omitempty"`
+	Files        []string `json:"files,omitempty"`
+}
+
+type Migration struct {
+	ID           string  `json:"id"`
+	Database     string  `json:"database,omitempty"`
+	Status       string  `json:"status"`
+	Irreversible bool    `json:"irreversible,omitempty"`
+	DurationMS   float64 `json:"duration_ms,omitempty"`
+	Error        string  `json:"error,omitempty"`
+}
+
+type Target struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Migrations int    `json:"migrations"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Problem struct {
+	File    string `json:"file"`
+	Message string `json:"message"`
+}
+
+// Finding is a lint finding on a pending migration.
+type Finding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Database string `json:"database,omitempty"`
+	Rule     string `json:"rule"`
+	Level    string `json:"level"`
+	Message  string `json:"message"`
+}
+
+func New(command string) *Document {
+	return &Document{Version: Version, Command: command, OK: true}
+}
+
+// Fail records err on the document and returns it, so commands can write
+// `return doc.Fail(err)` after deferring Write.
+func (d *Document) Fail(err error) error {
+	if err != nil {
+		d.OK = false
+		d.Error = err.Error()
+	}
+	return err
+}
+
+func (d *Document) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,43 @@
+package retry
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const MaxDelay = 30 * time.Second
+
+// Policy retries an operation up to Attempts more times, waiting Delay
+// before the first retry and doubling the wait after each one.
+type Policy struct {
+	Attempts int
+	Delay    time.Duration
+}
+
+// Do runs fn until it succeeds, fails with an error transient doesn't
+// accept, or the policy runs out of attempts. Every retry is logged.
+func Do(ctx context.Context, policy Policy, logger *slog.Logger, operation string, transient func(error) bool, fn func() error) error {
+	delay := policy.Delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > policy.Attempts || !transient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		logger.Warn("Retrying after transient error",
+			"operation", operation, "attempt", attempt, "of", policy.Attempts, "delay", delay, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay = backoff(delay)
+	}
+}
+
+// backoff doubles delay, up to MaxDelay.
+func backoff(delay time.Duration) time.Duration {
+	return min(delay*2, MaxDelay)
+}
@@ -0,0 +1,134 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	errTransient = errors.New("database is locked")
+	errPermanent = errors.New("syntax error")
+)
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    Policy
+		errs      []error // returned by the successive calls, then nil
+		wantCalls int
+		wantErr   error
+		wantLogs  []string
+	}{
+		{
+			name:      "success",
+			policy:    Policy{Attempts: 3, Delay: time.Millisecond},
+			wantCalls: 1,
+		},
+		{
+			name:      "transient error then success",
+			policy:    Policy{Attempts: 3, Delay: time.Millisecond},
+			errs:      []error{errTransient, errTransient},
+			wantCalls: 3,
+			wantLogs:  []string{"attempt=1 of=3 delay=1ms", "attempt=2 of=3 delay=2ms"},
+		},
+		{
+			name:      "out of attempts",
+			policy:    Policy{Attempts: 2, Delay: time.Millisecond},
+			errs:      []error{errTransient, errTransient, errTransient, errTransient},
+			wantCalls: 3,
+			wantErr:   errTransient,
+			wantLogs:  []string{"attempt=1 of=2 delay=1ms", "attempt=2 of=2 delay=2ms"},
+		},
+		{
+			name:      "permanent error",
+			policy:    Policy{Attempts: 3, Delay: time.Millisecond},
+			errs:      []error{errPermanent},
+			wantCalls: 1,
+			wantErr:   errPermanent,
+		},
+		{
+			name:      "no retries",
+			errs:      []error{errTransient},
+			wantCalls: 1,
+			wantErr:   errTransient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			calls := 0
+			err := Do(context.Background(), tt.policy, logger, "connect", func(err error) bool { return err == errTransient }, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Do() called fn %d times, want %d", calls, tt.wantCalls)
+			}
+
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			if logs.Len() == 0 {
+				lines = nil
+			}
+			if len(lines) != len(tt.wantLogs) {
+				t.Fatalf("Do() logged %q, want %d retries", logs.String(), len(tt.wantLogs))
+			}
+			for i, want := range tt.wantLogs {
+				if !strings.Contains(lines[i], "operation=connect "+want) {
+					t.Errorf("retry %d logged %q, want %q", i+1, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, Policy{Attempts: 5, Delay: time.Hour}, logger, "connect", func(error) bool { return true }, func() error {
+		calls++
+		cancel()
+		return errTransient
+	})
+	if err != errTransient || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want %v after 1", err, calls, errTransient)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() waited %s after the context was canceled", elapsed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  time.Duration
+	}{
+		{0, 0},
+		{time.Second, 2 * time.Second},
+		{10 * time.Second, 20 * time.Second},
+		{20 * time.Second, MaxDelay},
+		{MaxDelay, MaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.delay); got != tt.want {
+			t.Errorf("backoff(%s) = %s, want %s", tt.delay, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+var mermaidUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+func FilterTables(s *db.Schema, include, exclude []string) (*db.Schema, error) {
+	filtered := &db.Schema{Enums: s.Enums}
+	for _, table := range s.Tables {
+		keep := len(include) == 0
+		for _, pattern := range include {
+			ok, err := path.Match(pattern, table.FullName())
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			keep = keep || ok
+		}
+		for _, pattern := range exclude {
+			ok, err := path.Match(pattern, table.FullName())
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			keep = keep && !ok
+		}
+		if keep {
+			filtered.Tables = append(filtered.Tables, table)
+		}
+	}
+	return filtered, nil
+}
+
+func Diagram(s *db.Schema, format string) (string, error) {
+	switch format {
+	case "mermaid":
+		return mermaidDiagram(s), nil
+	case "dot":
+		return dotDiagram(s), nil
+	case "dbml":
+		return dbmlDiagram(s), nil
+	default:
+		return "", fmt.Errorf("unsupported diagram format: %s", format)
+	}
+}
+
+type relation struct {
+	from       db.Table
+	to         db.Table
+	columns    []string
+	refColumns []string
+	optional   bool
+}
+
+// relations returns the foreign keys whose both ends are part of the schema.
+func relations(s *db.Schema) []relation {
+	var rels []relation
+	for _, table := range s.Tables {
+		for _, fk := range table.ForeignKeys {
+			ref, ok := s.Table(fk.RefFullName())
+			if !ok {
+				continue
+			}
+			refColumns := fk.RefColumns
+			if len(refColumns) == 0 {
+				refColumns = ref.PrimaryKey
+			}
+			optional := false
+			for _, name := range fk.Columns {
+				if col, ok := table.Column(name); ok && col.Nullable {
+					optional = true
+				}
+			}
+			rels = append(rels, relation{table, ref, fk.Columns, refColumns, optional})
+		}
+	}
+	return rels
+}
+
+func keyMarkers(t db.Table, column string) []string {
+	var keys []string
+	if slices.Contains(t.PrimaryKey, column) {
+		keys = append(keys, "PK")
+	}
+	for _, fk := range t.ForeignKeys {
+		if slices.Contains(fk.Columns, column) {
+			keys = append(keys, "FK")
+			break
+		}
+	}
+	for _, uk := range t.UniqueKeys {
+		if len(uk.Columns) == 1 && uk.Columns[0] == column {
+			keys = append(keys, "UK")
+			break
+		}
+	}
+	return keys
+}
+
+func mermaidName(name string) string {
+	name = strings.Trim(mermaidUnsafe.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+func mermaidDiagram(s *db.Schema) string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, table := range s.Tables {
+		fmt.Fprintf(&b, "    %s {\n", mermaidName(table.FullName()))
+		for _, col := range table.Columns {
+			fmt.Fprintf(&b, "        %s %s", mermaidName(col.Type), mermaidName(col.Name))
+			if keys := keyMarkers(table, col.Name); len(keys) > 0 {
+				fmt.Fprintf(&b, " %s", strings.Join(keys, ","))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, rel := range relations(s) {
+		cardinality := "||--o{"
+		if rel.optional {
+			cardinality = "|o--o{"
+		}
+		fmt.Fprintf(&b, "    %s %s %s : %q\n",
+			mermaidName(rel.to.FullName()), cardinality, mermaidName(rel.from.FullName()), strings.Join(rel.columns, ", "))
+	}
+	return b.String()
+}
+
+func dotEscape(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`)
+	return replacer.Replace(s)
+}
+
+func dotDiagram(s *db.Schema) string {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n")
+	b.WriteString("    rankdir=LR;\n")
+	b.WriteString("    node [shape=record, fontname=\"Helvetica\"];\n\n")
+	for _, table := range s.Tables {
+		var fields []string
+		for _, col := range table.Columns {
+			field := fmt.Sprintf("<%s> %s : %s", dotEscape(col.Name), dotEscape(col.Name), dotEscape(col.Type))
+			if keys := keyMarkers(table, col.Name); len(keys) > 0 {
+				field += fmt.Sprintf(" (%s)", strings.Join(keys, ", "))
+			}
+			fields = append(fields, field+`\l`)
+		}
+		fmt.Fprintf(&b, "    %q [label=\"{%s|%s}\"];\n", table.FullName(), dotEscape(table.FullName()), strings.Join(fields, ""))
+	}
+	b.WriteString("\n")
+	for _, rel := range relations(s) {
+		fmt.Fprintf(&b, "    %q -> %q [label=%q];\n", rel.from.FullName(), rel.to.FullName(), strings.Join(rel.columns, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dbmlName(name string) string {
+	if mermaidName(name) == name {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
+
+func dbmlColumns(names []string) string {
+	if len(names) == 1 {
+		return dbmlName(names[0])
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = dbmlName(name)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+func dbmlDiagram(s *db.Schema) string {
+	var b strings.Builder
+	for _, enum := range s.Enums {
+		fmt.Fprintf(&b, "Enum %s {\n", dbmlName(enum.FullName()))
+		for _, value := range enum.Values {
+			fmt.Fprintf(&b, "  %s\n", dbmlName(value))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	for _, table := range s.Tables {
+		fmt.Fprintf(&b, "Table %s {\n", dbmlName(table.FullName()))
+		for _, col := range table.Columns {
+			var settings []string
+			if len(table.PrimaryKey) == 1 && table.PrimaryKey[0] == col.Name {
+				settings = append(settings, "pk")
+			}
+			if !col.Nullable {
+				settings = append(settings, "not null")
+			}
+			if slices.Contains(keyMarkers(table, col.Name), "UK") {
+				settings = append(settings, "unique")
+			}
+			if col.Default != nil {
+				settings = append(settings, fmt.Sprintf("default: `%s`", *col.Default))
+			}
+			fmt.Fprintf(&b, "  %s %s", dbmlName(col.Name), dbmlName(col.Type))
+			if len(settings) > 0 {
+				fmt.Fprintf(&b, " [%s]", strings.Join(settings, ", "))
+			}
+			b.WriteString("\n")
+		}
+		if len(table.PrimaryKey) > 1 || len(table.Indexes) > 0 {
+			b.WriteString("\n  indexes {\n")
+			if len(table.PrimaryKey) > 1 {
+				fmt.Fprintf(&b, "    %s [pk]\n", dbmlColumns(table.PrimaryKey))
+			}
+			for _, idx := range table.Indexes {
+				settings := fmt.Sprintf("name: %q", idx.Name)
+				if idx.Unique {
+					settings = "unique, " + settings
+				}
+				fmt.Fprintf(&b, "    %s [%s]\n", dbmlColumns(idx.Columns), settings)
+			}
+			b.WriteString("  }\n")
+		}
+		b.WriteString("}\n\n")
+	}
+
+	for _, rel := range relations(s) {
+		fmt.Fprintf(&b, "Ref: %s.%s > %s.%s\n",
+			dbmlName(rel.from.FullName()), dbmlColumns(rel.columns), dbmlName(rel.to.FullName()), dbmlColumns(rel.refColumns))
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
@@ -0,0 +1,422 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+const destructiveNote = "-- REVIEW: destructive operation, data may be lost."
+
+type change struct {
+	up        string
+	down      string
+	lossyUp   bool
+	lossyDown bool
+}
+
+type differ struct {
+	dialect string
+	changes []change
+}
+
+func DiffFile(ctx context.Context, driver db.Driver, desiredPath, dialect string) (string, string, error) {
+	ddl, err := os.ReadFile(desiredPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read desired schema: %w", err)
+	}
+
+	current, err := driver.InspectSchema(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to inspect database: %w", err)
+	}
+
+	desired, err := driver.InspectDDL(ctx, string(ddl))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to inspect desired schema: %w", err)
+	}
+
+	up, down := Diff(current, desired, dialect)
+	return up, down, nil
+}
+
+func Diff(current, desired *db.Schema, dialect string) (string, string) {
+	d := &differ{dialect: dialect}
+
+	for _, enum := range desired.Enums {
+		old, ok := current.Enum(enum.FullName())
+		if !ok {
+			d.add(change{
+				up:   enum.CreateStatement(),
+				down: fmt.Sprintf("DROP TYPE %s", enum.QualifiedName()),
+			})
+			continue
+		}
+		d.diffEnum(old, enum)
+	}
+
+	for _, seq := range desired.Sequences {
+		if _, ok := current.Sequence(seq.FullName()); !ok {
+			d.add(change{
+				up:   seq.CreateStatement(),
+				down: fmt.Sprintf("DROP SEQUENCE %s", seq.QualifiedName()),
+			})
+		}
+	}
+
+	for _, table := range createOrder(desired, current) {
+		d.add(change{
+			up:        table.CreateStatement(),
+			down:      fmt.Sprintf("DROP TABLE %s", table.QualifiedName()),
+			lossyDown: true,
+		})
+		for _, idx := range table.Indexes {
+			d.add(change{up: idx.Definition, down: dropIndex(table, idx)})
+		}
+	}
+
+	for _, table := range desired.Tables {
+		if old, ok := current.Table(table.FullName()); ok {
+			d.diffTable(old, table)
+		}
+	}
+
+	// removed tables are dropped before the tables they reference
+	removed := createOrder(current, desired)
+	for i := len(removed) - 1; i >= 0; i-- {
+		table := removed[i]
+		for _, idx := range table.Indexes {
+			d.add(change{up: dropIndex(table, idx), down: idx.Definition})
+		}
+		d.add(change{
+			up:      fmt.Sprintf("DROP TABLE %s", table.QualifiedName()),
+			down:    table.CreateStatement(),
+			lossyUp: true,
+		})
+	}
+
+	for _, seq := range current.Sequences {
+		if _, ok := desired.Sequence(seq.FullName()); !ok {
+			d.add(change{
+				up:   fmt.Sprintf("DROP SEQUENCE %s", seq.QualifiedName()),
+				down: seq.CreateStatement(),
+			})
+		}
+	}
+
+	for _, enum := range current.Enums {
+		if _, ok := desired.Enum(enum.FullName()); !ok {
+			d.add(change{
+				up:      fmt.Sprintf("DROP TYPE %s", enum.QualifiedName()),
+				down:    enum.CreateStatement(),
+				lossyUp: true,
+			})
+		}
+	}
+
+	return d.render()
+}
+
+func (d *differ) add(c change) {
+	d.changes = append(d.changes, c)
+}
+
+func (d *differ) manual(up, down string) {
+	d.add(change{up: "-- REVIEW: " + up, down: "-- REVIEW: " + down})
+}
+
+func (d *differ) render() (string, string) {
+	if len(d.changes) == 0 {
+		return "-- No schema changes detected.\n", "-- No schema changes detected.\n"
+	}
+
+	var up, down strings.Builder
+	for _, c := range d.changes {
+		writeStatement(&up, c.up, c.lossyUp)
+	}
+	for i := len(d.changes) - 1; i >= 0; i-- {
+		writeStatement(&down, d.changes[i].down, d.changes[i].lossyDown)
+	}
+	return up.String(), down.String()
+}
+
+func writeStatement(b *strings.Builder, stmt string, lossy bool) {
+	if stmt == "" {
+		return
+	}
+	if lossy {
+		b.WriteString(destructiveNote + "\n")
+	}
+	b.WriteString(stmt)
+	if !strings.HasPrefix(stmt, "--") {
+		b.WriteString(";")
+	}
+	b.WriteString("\n\n")
+}
+
+func (d *differ) diffEnum(old, desired db.Enum) {
+	if d.dialect != "postgres" {
+		return
+	}
+	for _, value := range desired.Values {
+		if slices.Contains(old.Values, value) {
+			continue
+		}
+		d.add(change{
+			up:   fmt.Sprintf("ALTER TYPE %s ADD VALUE %s", desired.QualifiedName(), db.QuoteLiteral(value)),
+			down: fmt.Sprintf("-- REVIEW: postgres cannot remove value %s from enum %s", db.QuoteLiteral(value), desired.Name),
+		})
+	}
+	for _, value := range old.Values {
+		if !slices.Contains(desired.Values, value) {
+			d.manual(
+				fmt.Sprintf("postgres cannot remove value %s from enum %s, recreate the type manually", db.QuoteLiteral(value), desired.Name),
+				fmt.Sprintf("value %s was removed from enum %s", db.QuoteLiteral(value), desired.Name),
+			)
+		}
+	}
+}
+
+func (d *differ) diffTable(old, desired db.Table) {
+	name := desired.QualifiedName()
+
+	for _, fk := range old.ForeignKeys {
+		if !containsForeignKey(desired.ForeignKeys, fk) {
+			d.dropConstraint(desired, fk.Name, fk.Definition())
+		}
+	}
+	for _, uk := range old.UniqueKeys {
+		if !containsUniqueKey(desired.UniqueKeys, uk) {
+			d.dropConstraint(desired, uk.Name, uk.Definition())
+		}
+	}
+	for _, check := range old.Checks {
+		if !containsCheck(desired.Checks, check) {
+			d.dropConstraint(desired, check.Name, check.Definition())
+		}
+	}
+	for _, idx := range old.Indexes {
+		if !containsIndex(desired.Indexes, idx) {
+			d.add(change{up: dropIndex(desired, idx), down: idx.Definition})
+		}
+	}
+
+	for _, col := range desired.Columns {
+		oldCol, ok := old.Column(col.Name)
+		if !ok {
+			if !col.Nullable && col.Default == nil {
+				d.add(change{up: fmt.Sprintf("-- REVIEW: column %s.%s is NOT NULL without a default, existing rows will fail", desired.Name, col.Name)})
+			}
+			d.add(change{
+				up:        fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", name, col.Definition()),
+				down:      fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", name, db.QuoteIdent(col.Name)),
+				lossyDown: true,
+			})
+			continue
+		}
+		d.diffColumn(desired, oldCol, col)
+	}
+
+	for _, col := range old.Columns {
+		if _, ok := desired.Column(col.Name); !ok {
+			d.add(change{
+				up:      fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", name, db.QuoteIdent(col.Name)),
+				down:    fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", name, col.Definition()),
+				lossyUp: true,
+			})
+		}
+	}
+
+	if !slices.Equal(old.PrimaryKey, desired.PrimaryKey) {
+		d.manual(
+			fmt.Sprintf("primary key of %s changes from (%s) to (%s), migrate it manually",
+				desired.Name, strings.Join(old.PrimaryKey, ", "), strings.Join(desired.PrimaryKey, ", ")),
+			fmt.Sprintf("restore primary key of %s to (%s)", desired.Name, strings.Join(old.PrimaryKey, ", ")),
+		)
+	}
+
+	for _, idx := range desired.Indexes {
+		if !containsIndex(old.Indexes, idx) {
+			d.add(change{up: idx.Definition, down: dropIndex(desired, idx)})
+		}
+	}
+	for _, uk := range desired.UniqueKeys {
+		if !containsUniqueKey(old.UniqueKeys, uk) {
+			d.addConstraint(desired, uk.Name, uk.Definition())
+		}
+	}
+	for _, fk := range desired.ForeignKeys {
+		if !containsForeignKey(old.ForeignKeys, fk) {
+			d.addConstraint(desired, fk.Name, fk.Definition())
+		}
+	}
+	for _, check := range desired.Checks {
+		if !containsCheck(old.Checks, check) {
+			d.addConstraint(desired, check.Name, check.Definition())
+		}
+	}
+}
+
+func (d *differ) diffColumn(table db.Table, old, desired db.Column) {
+	if old.Type == desired.Type && old.Nullable == desired.Nullable && equalDefault(old.Default, desired.Default) && old.Identity == desired.Identity {
+		return
+	}
+
+	if d.dialect != "postgres" {
+		d.manual(
+			fmt.Sprintf("sqlite cannot alter column %s.%s in place (%s -> %s), rebuild the table",
+				table.FullName(), desired.Name, old.Definition(), desired.Definition()),
+			fmt.Sprintf("restore column %s.%s to %s", table.FullName(), old.Name, old.Definition()),
+		)
+		return
+	}
+
+	// serial is no type postgres can alter a column to, it creates a
+	// sequence along with the column
+	if old.Type != desired.Type && (isSerial(old.Type) || isSerial(desired.Type)) {
+		d.manual(
+			fmt.Sprintf("column %s.%s changes from %s to %s, create or drop its sequence manually",
+				table.FullName(), desired.Name, old.Type, desired.Type),
+			fmt.Sprintf("restore column %s.%s to %s", table.FullName(), old.Name, old.Definition()),
+		)
+		return
+	}
+
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", table.QualifiedName(), db.QuoteIdent(desired.Name))
+	if old.Type != desired.Type {
+		d.add(change{
+			up:        fmt.Sprintf("%s TYPE %s", prefix, desired.Type),
+			down:      fmt.Sprintf("%s TYPE %s", prefix, old.Type),
+			lossyUp:   true,
+			lossyDown: true,
+		})
+	}
+	if old.Nullable != desired.Nullable {
+		setNotNull, dropNotNull := prefix+" SET NOT NULL", prefix+" DROP NOT NULL"
+		if desired.Nullable {
+			d.add(change{up: dropNotNull, down: setNotNull})
+		} else {
+			d.add(change{up: setNotNull, down: dropNotNull})
+		}
+	}
+	if !equalDefault(old.Default, desired.Default) {
+		d.add(change{up: setDefault(prefix, desired.Default), down: setDefault(prefix, old.Default)})
+	}
+	if old.Identity != desired.Identity {
+		d.add(change{up: setIdentity(prefix, old.Identity, desired.Identity), down: setIdentity(prefix, desired.Identity, old.Identity)})
+	}
+}
+
+func isSerial(typ string) bool {
+	return typ == "smallserial" || typ == "serial" || typ == "bigserial"
+}
+
+func setIdentity(prefix, from, to string) string {
+	switch {
+	case to == "":
+		return prefix + " DROP IDENTITY"
+	case from == "":
+		return fmt.Sprintf("%s ADD GENERATED %s AS IDENTITY", prefix, to)
+	default:
+		return fmt.Sprintf("%s SET GENERATED %s", prefix, to)
+	}
+}
+
+func dropIndex(table db.Table, idx db.Index) string {
+	return fmt.Sprintf("DROP INDEX %s", idx.QualifiedName(table))
+}
+
+func (d *differ) addConstraint(table db.Table, name, definition string) {
+	if d.dialect != "postgres" {
+		d.manual(
+			fmt.Sprintf("sqlite cannot add constraint to %s, rebuild the table with: %s", table.FullName(), definition),
+			fmt.Sprintf("constraint %s was added to %s", definition, table.FullName()),
+		)
+		return
+	}
+	d.add(change{
+		up:   fmt.Sprintf("ALTER TABLE %s ADD %s", table.QualifiedName(), definition),
+		down: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table.QualifiedName(), db.QuoteIdent(name)),
+	})
+}
+
+func (d *differ) dropConstraint(table db.Table, name, definition string) {
+	if d.dialect != "postgres" || name == "" {
+		d.manual(
+			fmt.Sprintf("constraint %s cannot be dropped from %s in place, rebuild the table", definition, table.FullName()),
+			fmt.Sprintf("restore constraint %s on %s", definition, table.FullName()),
+		)
+		return
+	}
+	d.add(change{
+		up:   fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table.QualifiedName(), db.QuoteIdent(name)),
+		down: fmt.Sprintf("ALTER TABLE %s ADD %s", table.QualifiedName(), definition),
+	})
+}
+
+// createOrder returns the tables missing from current, ordered so that
+// referenced tables are created before the tables pointing at them. Called
+// the other way around, it lists the tables to drop in reverse drop order.
+func createOrder(desired, current *db.Schema) []db.Table {
+	var missing []db.Table
+	for _, table := range desired.Tables {
+		if _, ok := current.Table(table.FullName()); !ok {
+			missing = append(missing, table)
+		}
+	}
+	return db.OrderTables(missing)
+}
+
+func containsIndex(indexes []db.Index, idx db.Index) bool {
+	for _, other := range indexes {
+		if other.Name == idx.Name && other.Unique == idx.Unique && slices.Equal(other.Columns, idx.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUniqueKey(keys []db.UniqueKey, key db.UniqueKey) bool {
+	for _, other := range keys {
+		if slices.Equal(other.Columns, key.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCheck(checks []db.Check, check db.Check) bool {
+	for _, other := range checks {
+		if strings.Join(strings.Fields(other.Expression), " ") == strings.Join(strings.Fields(check.Expression), " ") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsForeignKey(fks []db.ForeignKey, fk db.ForeignKey) bool {
+	for _, other := range fks {
+		if other.RefTable == fk.RefTable && slices.Equal(other.Columns, fk.Columns) && slices.Equal(other.RefColumns, fk.RefColumns) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalDefault(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func setDefault(prefix string, value *string) string {
+	if value == nil {
+		return prefix + " DROP DEFAULT"
+	}
+	return fmt.Sprintf("%s SET DEFAULT %s", prefix, *value)
+}
@@ -0,0 +1,251 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jxdones/vagabond/internal/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDiff(t *testing.T) {
+	users := db.Table{
+		Name:       "users",
+		Columns:    []db.Column{{Name: "id", Type: "bigint", Identity: "ALWAYS"}},
+		PrimaryKey: []string{"id"},
+	}
+	posts := db.Table{
+		Name: "posts",
+		Columns: []db.Column{
+			{Name: "id", Type: "serial"},
+			{Name: "user_id", Type: "bigint"},
+			{Name: "price", Type: "numeric"},
+		},
+		PrimaryKey:  []string{"id"},
+		ForeignKeys: []db.ForeignKey{{Name: "posts_user_id_fkey", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}}},
+		Checks:      []db.Check{{Name: "posts_price_check", Expression: "(price > (0)::numeric)"}},
+	}
+	withCheck := func(t db.Table, checks ...db.Check) db.Table {
+		t.Checks = checks
+		return t
+	}
+	withIdentity := func(t db.Table, identity string) db.Table {
+		t.Columns = []db.Column{{Name: "id", Type: "bigint", Identity: identity}}
+		return t
+	}
+
+	tests := []struct {
+		name     string
+		current  *db.Schema
+		desired  *db.Schema
+		dialect  string
+		wantUp   []string
+		wantDown []string
+	}{
+		{
+			name:    "new tables keep checks, identity and serial, referenced tables first",
+			current: &db.Schema{},
+			desired: &db.Schema{Tables: []db.Table{posts, users}},
+			dialect: "postgres",
+			wantUp: []string{
+				"CREATE TABLE \"users\" (\n  \"id\" bigint GENERATED ALWAYS AS IDENTITY NOT NULL,\n  PRIMARY KEY (\"id\")\n);",
+				"CREATE TABLE \"posts\" (\n  \"id\" serial NOT NULL,\n  \"user_id\" bigint NOT NULL,\n  \"price\" numeric NOT NULL,\n  PRIMARY KEY (\"id\"),\n" +
+					"  CONSTRAINT \"posts_user_id_fkey\" FOREIGN KEY (\"user_id\") REFERENCES \"users\" (\"id\"),\n" +
+					"  CONSTRAINT \"posts_price_check\" CHECK ((price > (0)::numeric))\n);",
+			},
+			wantDown: []string{
+				"DROP TABLE \"posts\";",
+				"DROP TABLE \"users\";",
+			},
+		},
+		{
+			name:    "removed tables are dropped referencing tables first",
+			current: &db.Schema{Tables: []db.Table{posts, users}},
+			desired: &db.Schema{},
+			dialect: "postgres",
+			wantUp: []string{
+				"DROP TABLE \"posts\";",
+				"DROP TABLE \"users\";",
+			},
+			wantDown: []string{
+				"CREATE TABLE \"users\"",
+				"CREATE TABLE \"posts\"",
+			},
+		},
+		{
+			name:    "sqlite tables are created from their definition",
+			current: &db.Schema{},
+			desired: &db.Schema{Tables: []db.Table{{
+				Name:       "posts",
+				Columns:    []db.Column{{Name: "id", Type: "INTEGER"}},
+				PrimaryKey: []string{"id"},
+				Definition: "CREATE TABLE posts (id INTEGER PRIMARY KEY AUTOINCREMENT, price REAL CHECK (price > 0))",
+			}}},
+			dialect:  "sqlite",
+			wantUp:   []string{"CREATE TABLE posts (id INTEGER PRIMARY KEY AUTOINCREMENT, price REAL CHECK (price > 0));"},
+			wantDown: []string{"DROP TABLE \"posts\";"},
+		},
+		{
+			name:     "added check",
+			current:  &db.Schema{Tables: []db.Table{withCheck(posts)}},
+			desired:  &db.Schema{Tables: []db.Table{posts}},
+			dialect:  "postgres",
+			wantUp:   []string{"ALTER TABLE \"posts\" ADD CONSTRAINT \"posts_price_check\" CHECK ((price > (0)::numeric));"},
+			wantDown: []string{"ALTER TABLE \"posts\" DROP CONSTRAINT \"posts_price_check\";"},
+		},
+		{
+			name:     "removed check",
+			current:  &db.Schema{Tables: []db.Table{posts}},
+			desired:  &db.Schema{Tables: []db.Table{withCheck(posts)}},
+			dialect:  "postgres",
+			wantUp:   []string{"ALTER TABLE \"posts\" DROP CONSTRAINT \"posts_price_check\";"},
+			wantDown: []string{"ALTER TABLE \"posts\" ADD CONSTRAINT \"posts_price_check\" CHECK ((price > (0)::numeric));"},
+		},
+		{
+			name:     "sqlite check needs a rebuild",
+			current:  &db.Schema{Tables: []db.Table{withCheck(posts)}},
+			desired:  &db.Schema{Tables: []db.Table{withCheck(posts, db.Check{Expression: "price > 0"})}},
+			dialect:  "sqlite",
+			wantUp:   []string{"-- REVIEW: sqlite cannot add constraint to posts, rebuild the table with: CHECK (price > 0)"},
+			wantDown: []string{"-- REVIEW: constraint CHECK (price > 0) was added to posts"},
+		},
+		{
+			name:     "identity added",
+			current:  &db.Schema{Tables: []db.Table{withIdentity(users, "")}},
+			desired:  &db.Schema{Tables: []db.Table{users}},
+			dialect:  "postgres",
+			wantUp:   []string{"ALTER TABLE \"users\" ALTER COLUMN \"id\" ADD GENERATED ALWAYS AS IDENTITY;"},
+			wantDown: []string{"ALTER TABLE \"users\" ALTER COLUMN \"id\" DROP IDENTITY;"},
+		},
+		{
+			name:     "identity changed",
+			current:  &db.Schema{Tables: []db.Table{users}},
+			desired:  &db.Schema{Tables: []db.Table{withIdentity(users, "BY DEFAULT")}},
+			dialect:  "postgres",
+			wantUp:   []string{"ALTER TABLE \"users\" ALTER COLUMN \"id\" SET GENERATED BY DEFAULT;"},
+			wantDown: []string{"ALTER TABLE \"users\" ALTER COLUMN \"id\" SET GENERATED ALWAYS;"},
+		},
+		{
+			name:    "column turned into serial",
+			current: &db.Schema{Tables: []db.Table{{Name: "t", Columns: []db.Column{{Name: "id", Type: "integer"}}}}},
+			desired: &db.Schema{Tables: []db.Table{{Name: "t", Columns: []db.Column{{Name: "id", Type: "serial"}}}}},
+			dialect: "postgres",
+			wantUp:  []string{"-- REVIEW: column t.id changes from integer to serial, create or drop its sequence manually"},
+		},
+		{
+			name:     "sequences",
+			current:  &db.Schema{Sequences: []db.Sequence{{Name: "old_seq", Start: 1, Increment: 1}}},
+			desired:  &db.Schema{Sequences: []db.Sequence{{Schema: "billing", Name: "invoice_number", Start: 1000, Increment: 1}}},
+			dialect:  "postgres",
+			wantUp:   []string{"CREATE SEQUENCE \"billing\".\"invoice_number\" START WITH 1000;", "DROP SEQUENCE \"old_seq\";"},
+			wantDown: []string{"CREATE SEQUENCE \"old_seq\";", "DROP SEQUENCE \"billing\".\"invoice_number\";"},
+		},
+		{
+			name:     "no changes",
+			current:  &db.Schema{Tables: []db.Table{users, posts}},
+			desired:  &db.Schema{Tables: []db.Table{posts, users}},
+			dialect:  "postgres",
+			wantUp:   []string{"-- No schema changes detected."},
+			wantDown: []string{"-- No schema changes detected."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down := Diff(tt.current, tt.desired, tt.dialect)
+			assertInOrder(t, "up", up, tt.wantUp)
+			assertInOrder(t, "down", down, tt.wantDown)
+		})
+	}
+}
+
+// assertInOrder checks that got contains want in the given order.
+func assertInOrder(t *testing.T, name, got string, want []string) {
+	t.Helper()
+	rest := got
+	for _, w := range want {
+		i := strings.Index(rest, w)
+		if i < 0 {
+			t.Errorf("%s does not contain %q after the previous statements:\n%s", name, w, got)
+			return
+		}
+		rest = rest[i+len(w):]
+	}
+}
+
+// TestDiffSQLiteRoundTrip applies the up migration to the current database
+// and checks it then has the desired schema, and that down brings it back.
+func TestDiffSQLiteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	current := `
+CREATE TABLE authors (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+CREATE TABLE books (id INTEGER PRIMARY KEY, author_id INTEGER REFERENCES authors (id));
+`
+	desired := `
+CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT NOT NULL CHECK (email LIKE '%@%'));
+CREATE TABLE posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    -- a check (in a comment) is not a check
+    title TEXT NOT NULL DEFAULT 'check (none)',
+    CONSTRAINT positive_id CHECK (id > 0)
+);
+CREATE INDEX posts_user_id ON posts (user_id);
+`
+
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON;"+current); err != nil {
+		t.Fatal(err)
+	}
+	driver, err := db.NewSQLite(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := driver.InspectSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := driver.InspectDDL(ctx, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := want.Tables[0].Checks; !reflect.DeepEqual(got, []db.Check{{Name: "positive_id", Expression: "id > 0"}}) {
+		t.Errorf("checks of posts = %v", got)
+	}
+
+	up, down := Diff(before, want, "sqlite")
+	if !strings.Contains(up, "AUTOINCREMENT") || !strings.Contains(up, "CHECK (email LIKE '%@%')") {
+		t.Errorf("up lost AUTOINCREMENT or CHECK:\n%s", up)
+	}
+
+	if _, err := conn.ExecContext(ctx, up); err != nil {
+		t.Fatalf("applying up: %v\n%s", err, up)
+	}
+	after, err := driver.InspectSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, want) {
+		t.Errorf("schema after up = %+v, want %+v", after, want)
+	}
+
+	if _, err := conn.ExecContext(ctx, down); err != nil {
+		t.Fatalf("applying down: %v\n%s", err, down)
+	}
+	restored, err := driver.InspectSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, before) {
+		t.Errorf("schema after down = %+v, want %+v", restored, before)
+	}
+}
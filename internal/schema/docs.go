@@ -0,0 +1,231 @@
+package schema
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+type Page struct {
+	Name    string
+	Content string
+}
+
+type reference struct {
+	table      string
+	columns    []string
+	refColumns []string
+}
+
+type tableDoc struct {
+	table    db.Table
+	outgoing []reference
+	incoming []reference
+	history  []string
+}
+
+func Docs(s *db.Schema, history map[string][]string, format string) ([]Page, error) {
+	var docs []tableDoc
+	for _, table := range s.Tables {
+		doc := tableDoc{table: table, history: history[table.FullName()]}
+		for _, fk := range table.ForeignKeys {
+			refColumns := fk.RefColumns
+			if ref, ok := s.Table(fk.RefFullName()); ok && len(refColumns) == 0 {
+				refColumns = ref.PrimaryKey
+			}
+			doc.outgoing = append(doc.outgoing, reference{fk.RefFullName(), fk.Columns, refColumns})
+		}
+		for _, other := range s.Tables {
+			for _, fk := range other.ForeignKeys {
+				if fk.RefFullName() == table.FullName() {
+					doc.incoming = append(doc.incoming, reference{other.FullName(), fk.Columns, fk.RefColumns})
+				}
+			}
+		}
+		docs = append(docs, doc)
+	}
+
+	switch format {
+	case "markdown":
+		return markdownDocs(docs), nil
+	case "html":
+		return htmlDocs(docs), nil
+	default:
+		return nil, fmt.Errorf("unsupported docs format: %s", format)
+	}
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func defaultValue(c db.Column) string {
+	if c.Default == nil {
+		return ""
+	}
+	return *c.Default
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func markdownDocs(docs []tableDoc) []Page {
+	var index strings.Builder
+	index.WriteString("# Data dictionary\n\n")
+	index.WriteString("| Table | Description |\n|---|---|\n")
+
+	pages := []Page{}
+	for _, doc := range docs {
+		t := doc.table
+		fmt.Fprintf(&index, "| [%s](%s.md) | %s |\n", markdownCell(t.FullName()), t.FullName(), markdownCell(t.Comment))
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s\n\n", t.FullName())
+		if t.Comment != "" {
+			fmt.Fprintf(&b, "%s\n\n", t.Comment)
+		}
+
+		b.WriteString("## Columns\n\n")
+		b.WriteString("| Column | Type | Nullable | Default | Keys | Description |\n|---|---|---|---|---|---|\n")
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+				markdownCell(c.Name), markdownCell(c.Type), yesNo(c.Nullable), markdownCell(defaultValue(c)),
+				strings.Join(keyMarkers(t, c.Name), ", "), markdownCell(c.Comment))
+		}
+
+		if len(t.Indexes) > 0 || len(t.UniqueKeys) > 0 {
+			b.WriteString("\n## Indexes\n\n")
+			b.WriteString("| Name | Columns | Unique |\n|---|---|---|\n")
+			for _, uk := range t.UniqueKeys {
+				fmt.Fprintf(&b, "| %s | %s | yes |\n", markdownCell(uk.Name), markdownCell(strings.Join(uk.Columns, ", ")))
+			}
+			for _, idx := range t.Indexes {
+				fmt.Fprintf(&b, "| %s | %s | %s |\n", markdownCell(idx.Name), markdownCell(strings.Join(idx.Columns, ", ")), yesNo(idx.Unique))
+			}
+		}
+
+		if len(doc.outgoing) > 0 {
+			b.WriteString("\n## References\n\n")
+			for _, ref := range doc.outgoing {
+				fmt.Fprintf(&b, "- (%s) → [%s](%s.md) (%s)\n",
+					strings.Join(ref.columns, ", "), ref.table, ref.table, strings.Join(ref.refColumns, ", "))
+			}
+		}
+
+		if len(doc.incoming) > 0 {
+			b.WriteString("\n## Referenced by\n\n")
+			for _, ref := range doc.incoming {
+				fmt.Fprintf(&b, "- [%s](%s.md) (%s)\n", ref.table, ref.table, strings.Join(ref.columns, ", "))
+			}
+		}
+
+		if len(doc.history) > 0 {
+			b.WriteString("\n## Migrations\n\n")
+			for _, id := range doc.history {
+				fmt.Fprintf(&b, "- `%s`\n", id)
+			}
+		}
+
+		b.WriteString("\n[Back to index](index.md)\n")
+		pages = append(pages, Page{Name: t.FullName() + ".md", Content: b.String()})
+	}
+
+	return append([]Page{{Name: "index.md", Content: index.String()}}, pages...)
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+table { border-collapse: collapse; width: 100%%; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f4f4f4; }
+code { background: #f4f4f4; padding: 0 0.2em; }
+</style>
+</head>
+<body>
+`
+
+const htmlFooter = "</body>\n</html>\n"
+
+func htmlDocs(docs []tableDoc) []Page {
+	e := html.EscapeString
+
+	var index strings.Builder
+	fmt.Fprintf(&index, htmlHeader, "Data dictionary")
+	index.WriteString("<h1>Data dictionary</h1>\n<table>\n<tr><th>Table</th><th>Description</th></tr>\n")
+
+	pages := []Page{}
+	for _, doc := range docs {
+		t := doc.table
+		fmt.Fprintf(&index, "<tr><td><a href=\"%s.html\">%s</a></td><td>%s</td></tr>\n", e(t.FullName()), e(t.FullName()), e(t.Comment))
+
+		var b strings.Builder
+		fmt.Fprintf(&b, htmlHeader, e(t.FullName()))
+		fmt.Fprintf(&b, "<p><a href=\"index.html\">Data dictionary</a></p>\n<h1>%s</h1>\n", e(t.FullName()))
+		if t.Comment != "" {
+			fmt.Fprintf(&b, "<p>%s</p>\n", e(t.Comment))
+		}
+
+		b.WriteString("<h2>Columns</h2>\n<table>\n")
+		b.WriteString("<tr><th>Column</th><th>Type</th><th>Nullable</th><th>Default</th><th>Keys</th><th>Description</th></tr>\n")
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td><code>%s</code></td><td>%s</td><td>%s</td></tr>\n",
+				e(c.Name), e(c.Type), yesNo(c.Nullable), e(defaultValue(c)),
+				strings.Join(keyMarkers(t, c.Name), ", "), e(c.Comment))
+		}
+		b.WriteString("</table>\n")
+
+		if len(t.Indexes) > 0 || len(t.UniqueKeys) > 0 {
+			b.WriteString("<h2>Indexes</h2>\n<table>\n<tr><th>Name</th><th>Columns</th><th>Unique</th></tr>\n")
+			for _, uk := range t.UniqueKeys {
+				fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>yes</td></tr>\n", e(uk.Name), e(strings.Join(uk.Columns, ", ")))
+			}
+			for _, idx := range t.Indexes {
+				fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>\n", e(idx.Name), e(strings.Join(idx.Columns, ", ")), yesNo(idx.Unique))
+			}
+			b.WriteString("</table>\n")
+		}
+
+		if len(doc.outgoing) > 0 {
+			b.WriteString("<h2>References</h2>\n<ul>\n")
+			for _, ref := range doc.outgoing {
+				fmt.Fprintf(&b, "<li>(%s) &rarr; <a href=\"%s.html\">%s</a> (%s)</li>\n",
+					e(strings.Join(ref.columns, ", ")), e(ref.table), e(ref.table), e(strings.Join(ref.refColumns, ", ")))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		if len(doc.incoming) > 0 {
+			b.WriteString("<h2>Referenced by</h2>\n<ul>\n")
+			for _, ref := range doc.incoming {
+				fmt.Fprintf(&b, "<li><a href=\"%s.html\">%s</a> (%s)</li>\n", e(ref.table), e(ref.table), e(strings.Join(ref.columns, ", ")))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		if len(doc.history) > 0 {
+			b.WriteString("<h2>Migrations</h2>\n<ul>\n")
+			for _, id := range doc.history {
+				fmt.Fprintf(&b, "<li><code>%s</code></li>\n", e(id))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+		b.WriteString(htmlFooter)
+		pages = append(pages, Page{Name: t.FullName() + ".html", Content: b.String()})
+	}
+
+	index.WriteString("</table>\n" + htmlFooter)
+	return append([]Page{{Name: "index.html", Content: index.String()}}, pages...)
+}
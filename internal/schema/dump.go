@@ -1,14 +1,15 @@
 package schema
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/jxdones/vagabond/internal/db"
 )
 
-func DumpSchema(driver db.Driver, outputPath string) error {
-	schema, err := driver.DumpSchema()
+func DumpSchema(ctx context.Context, driver db.Driver, outputPath string) error {
+	schema, err := driver.DumpSchema(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to dump schema: %w", err)
 	}
@@ -0,0 +1,258 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jxdones/vagabond/internal/db"
+)
+
+const exportVersion = 1
+
+type Document struct {
+	Version int        `json:"version"`
+	Dialect string     `json:"dialect"`
+	Tables  []db.Table `json:"tables"`
+	Enums   []db.Enum  `json:"enums"`
+}
+
+func FileName(format string) string {
+	return "schema." + format
+}
+
+func ExportSchema(ctx context.Context, driver db.Driver, dialect, format, outputPath string) error {
+	if format == "sql" {
+		return DumpSchema(ctx, driver, outputPath)
+	}
+
+	doc, err := Inspect(ctx, driver, dialect)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode schema: %w", err)
+		}
+		data = append(data, '\n')
+	case "yaml":
+		data = []byte(encodeYAML(doc))
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to create schema file: %w", err)
+	}
+	return nil
+}
+
+func Inspect(ctx context.Context, driver db.Driver, dialect string) (*Document, error) {
+	model, err := driver.InspectSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+
+	doc := &Document{
+		Version: exportVersion,
+		Dialect: dialect,
+		Tables:  model.Tables,
+		Enums:   model.Enums,
+	}
+	normalize(doc)
+	return doc, nil
+}
+
+// normalize sorts everything except columns, which keep their table order,
+// and replaces nil slices so the output is stable across databases.
+func normalize(doc *Document) {
+	if doc.Tables == nil {
+		doc.Tables = []db.Table{}
+	}
+	if doc.Enums == nil {
+		doc.Enums = []db.Enum{}
+	}
+	sort.Slice(doc.Tables, func(i, j int) bool { return doc.Tables[i].FullName() < doc.Tables[j].FullName() })
+	sort.Slice(doc.Enums, func(i, j int) bool { return doc.Enums[i].FullName() < doc.Enums[j].FullName() })
+
+	for i := range doc.Tables {
+		t := &doc.Tables[i]
+		t.Columns = nonNil(t.Columns)
+		t.PrimaryKey = nonNil(t.PrimaryKey)
+		t.UniqueKeys = nonNil(t.UniqueKeys)
+		t.ForeignKeys = nonNil(t.ForeignKeys)
+		t.Indexes = nonNil(t.Indexes)
+
+		sort.Slice(t.UniqueKeys, func(a, b int) bool {
+			return strings.Join(t.UniqueKeys[a].Columns, ",") < strings.Join(t.UniqueKeys[b].Columns, ",")
+		})
+		sort.Slice(t.ForeignKeys, func(a, b int) bool {
+			return foreignKeySortKey(t.ForeignKeys[a]) < foreignKeySortKey(t.ForeignKeys[b])
+		})
+		sort.Slice(t.Indexes, func(a, b int) bool { return t.Indexes[a].Name < t.Indexes[b].Name })
+
+		for j := range t.UniqueKeys {
+			t.UniqueKeys[j].Columns = nonNil(t.UniqueKeys[j].Columns)
+		}
+		for j := range t.ForeignKeys {
+			t.ForeignKeys[j].Columns = nonNil(t.ForeignKeys[j].Columns)
+			t.ForeignKeys[j].RefColumns = nonNil(t.ForeignKeys[j].RefColumns)
+		}
+		for j := range t.Indexes {
+			t.Indexes[j].Columns = nonNil(t.Indexes[j].Columns)
+		}
+	}
+	for i := range doc.Enums {
+		doc.Enums[i].Values = nonNil(doc.Enums[i].Values)
+	}
+}
+
+func foreignKeySortKey(fk db.ForeignKey) string {
+	return fk.Name + "\x00" + strings.Join(fk.Columns, ",") + "\x00" + fk.RefTable
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+func encodeYAML(doc *Document) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "version: %d\n", doc.Version)
+	fmt.Fprintf(&b, "dialect: %s\n", yamlString(doc.Dialect))
+
+	if len(doc.Tables) == 0 {
+		b.WriteString("tables: []\n")
+	} else {
+		b.WriteString("tables:\n")
+	}
+	for _, t := range doc.Tables {
+		// same-named tables in different schemas are told apart by schema,
+		// which like in JSON is left out when empty
+		b.WriteString("  - ")
+		if t.Schema != "" {
+			fmt.Fprintf(&b, "schema: %s\n    ", yamlString(t.Schema))
+		}
+		fmt.Fprintf(&b, "name: %s\n", yamlString(t.Name))
+		if t.Comment != "" {
+			fmt.Fprintf(&b, "    comment: %s\n", yamlString(t.Comment))
+		}
+		writeYAMLSection(&b, "columns", len(t.Columns))
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "      - name: %s\n", yamlString(c.Name))
+			fmt.Fprintf(&b, "        type: %s\n", yamlString(c.Type))
+			fmt.Fprintf(&b, "        nullable: %t\n", c.Nullable)
+			if c.Default == nil {
+				b.WriteString("        default: null\n")
+			} else {
+				fmt.Fprintf(&b, "        default: %s\n", yamlString(*c.Default))
+			}
+			if c.Identity != "" {
+				fmt.Fprintf(&b, "        identity: %s\n", yamlString(c.Identity))
+			}
+			if c.Comment != "" {
+				fmt.Fprintf(&b, "        comment: %s\n", yamlString(c.Comment))
+			}
+		}
+		fmt.Fprintf(&b, "    primary_key: %s\n", yamlList(t.PrimaryKey))
+
+		writeYAMLSection(&b, "unique_keys", len(t.UniqueKeys))
+		for _, u := range t.UniqueKeys {
+			b.WriteString("      - ")
+			if u.Name != "" {
+				fmt.Fprintf(&b, "name: %s\n        ", yamlString(u.Name))
+			}
+			fmt.Fprintf(&b, "columns: %s\n", yamlList(u.Columns))
+		}
+
+		writeYAMLSection(&b, "foreign_keys", len(t.ForeignKeys))
+		for _, fk := range t.ForeignKeys {
+			b.WriteString("      - ")
+			if fk.Name != "" {
+				fmt.Fprintf(&b, "name: %s\n        ", yamlString(fk.Name))
+			}
+			fmt.Fprintf(&b, "columns: %s\n", yamlList(fk.Columns))
+			if fk.RefSchema != "" {
+				fmt.Fprintf(&b, "        ref_schema: %s\n", yamlString(fk.RefSchema))
+			}
+			fmt.Fprintf(&b, "        ref_table: %s\n", yamlString(fk.RefTable))
+			fmt.Fprintf(&b, "        ref_columns: %s\n", yamlList(fk.RefColumns))
+		}
+
+		// like in JSON, checks are left out when there are none
+		if len(t.Checks) > 0 {
+			b.WriteString("    checks:\n")
+		}
+		for _, check := range t.Checks {
+			b.WriteString("      - ")
+			if check.Name != "" {
+				fmt.Fprintf(&b, "name: %s\n        ", yamlString(check.Name))
+			}
+			fmt.Fprintf(&b, "expression: %s\n", yamlString(check.Expression))
+		}
+
+		writeYAMLSection(&b, "indexes", len(t.Indexes))
+		for _, idx := range t.Indexes {
+			fmt.Fprintf(&b, "      - name: %s\n", yamlString(idx.Name))
+			fmt.Fprintf(&b, "        columns: %s\n", yamlList(idx.Columns))
+			fmt.Fprintf(&b, "        unique: %t\n", idx.Unique)
+			fmt.Fprintf(&b, "        definition: %s\n", yamlString(idx.Definition))
+		}
+	}
+
+	if len(doc.Enums) == 0 {
+		b.WriteString("enums: []\n")
+	} else {
+		b.WriteString("enums:\n")
+	}
+	for _, e := range doc.Enums {
+		b.WriteString("  - ")
+		if e.Schema != "" {
+			fmt.Fprintf(&b, "schema: %s\n    ", yamlString(e.Schema))
+		}
+		fmt.Fprintf(&b, "name: %s\n", yamlString(e.Name))
+		fmt.Fprintf(&b, "    values: %s\n", yamlList(e.Values))
+	}
+	return b.String()
+}
+
+func writeYAMLSection(b *strings.Builder, key string, n int) {
+	if n == 0 {
+		fmt.Fprintf(b, "    %s: []\n", key)
+		return
+	}
+	fmt.Fprintf(b, "    %s:\n", key)
+}
+
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ .()]*$`)
+
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		return `"` + s + `"`
+	}
+	if yamlPlain.MatchString(s) && !strings.HasSuffix(s, " ") {
+		return s
+	}
+	// JSON strings are valid double-quoted YAML scalars
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+func yamlList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = yamlString(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/jxdones/vagabond/internal/db"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEncodeYAMLSchemas(t *testing.T) {
+	doc := &Document{
+		Version: exportVersion,
+		Dialect: "postgres",
+		Tables: []db.Table{
+			{
+				Schema:     "billing",
+				Name:       "users",
+				Columns:    []db.Column{{Name: "id", Type: "integer"}},
+				PrimaryKey: []string{"id"},
+				ForeignKeys: []db.ForeignKey{
+					{Columns: []string{"id"}, RefSchema: "public", RefTable: "users", RefColumns: []string{"id"}},
+				},
+			},
+			{Name: "plain", Columns: []db.Column{{Name: "id", Type: "integer", Nullable: true}}},
+		},
+		Enums: []db.Enum{{Schema: "billing", Name: "plan", Values: []string{"free"}}},
+	}
+
+	want := `version: 1
+dialect: postgres
+tables:
+  - schema: billing
+    name: users
+    columns:
+      - name: id
+        type: integer
+        nullable: false
+        default: null
+    primary_key: [id]
+    unique_keys: []
+    foreign_keys:
+      - columns: [id]
+        ref_schema: public
+        ref_table: users
+        ref_columns: [id]
+    indexes: []
+  - name: plain
+    columns:
+      - name: id
+        type: integer
+        nullable: true
+        default: null
+    primary_key: []
+    unique_keys: []
+    foreign_keys: []
+    indexes: []
+enums:
+  - schema: billing
+    name: plan
+    values: [free]
+`
+	if got := encodeYAML(doc); got != want {
+		t.Errorf("encodeYAML() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestEncodeYAMLRoundTrip parses the YAML back with a YAML parser, it has to
+// hold the same document as the JSON export.
+func TestEncodeYAMLRoundTrip(t *testing.T) {
+	def, tricky := "now()", "it's: \"quoted\"\n# not a comment"
+	tests := []struct {
+		name string
+		doc  *Document
+	}{
+		{
+			name: "empty schema",
+			doc:  &Document{Version: exportVersion, Dialect: "sqlite"},
+		},
+		{
+			name: "table without columns",
+			doc:  &Document{Version: exportVersion, Dialect: "postgres", Tables: []db.Table{{Name: "empty"}}},
+		},
+		{
+			name: "everything",
+			doc: &Document{
+				Version: exportVersion,
+				Dialect: "postgres",
+				Tables: []db.Table{{
+					Schema:  "billing",
+					Name:    "invoices",
+					Comment: tricky,
+					Columns: []db.Column{
+						{Name: "id", Type: "bigint", Identity: "ALWAYS"},
+						{Name: "created_at", Type: "timestamp with time zone", Default: &def, Nullable: true},
+						{Name: "yes", Type: "character varying(20)", Default: &tricky, Comment: "null"},
+						{Name: " padded", Type: "integer[]"},
+					},
+					PrimaryKey:  []string{"id"},
+					UniqueKeys:  []db.UniqueKey{{Columns: []string{"yes", "1"}}, {Name: "invoices_key", Columns: []string{"id"}}},
+					ForeignKeys: []db.ForeignKey{{Name: "fk", Columns: []string{"id"}, RefSchema: "public", RefTable: "users", RefColumns: []string{"id"}}},
+					Checks:      []db.Check{{Name: "positive", Expression: "(id > 0)"}, {Expression: "yes <> ''"}},
+					Indexes:     []db.Index{{Name: "idx", Columns: []string{"lower(yes)"}, Definition: "CREATE INDEX idx ON billing.invoices USING btree (lower(yes))"}},
+				}},
+				Enums: []db.Enum{{Name: "status", Values: []string{"on", "off", "true", "1.0", ""}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalize(tt.doc)
+
+			var fromYAML any
+			if err := yaml.Unmarshal([]byte(encodeYAML(tt.doc)), &fromYAML); err != nil {
+				t.Fatalf("yaml.Unmarshal() error = %v\n%s", err, encodeYAML(tt.doc))
+			}
+			// through JSON, so that numbers and maps have the same types
+			// on both sides
+			got := jsonRoundTrip(t, fromYAML)
+			want := jsonRoundTrip(t, tt.doc)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("YAML holds\n%v\nwant\n%v\nYAML:\n%s", got, want, encodeYAML(tt.doc))
+			}
+		})
+	}
+}
+
+func jsonRoundTrip(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package sqlscript
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	placeholder = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	template    = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// Expand replaces ${name} and {{ .name }} placeholders with the values
+// lookup returns. A doubled $${name} is kept as a literal ${name}.
+// Placeholders in comments and postgres dollar-quoted bodies are left alone,
+// those in quoted strings are expanded. Undefined variables are an error,
+// listed together so they can be fixed in one go.
+func Expand(sql string, lookup func(name string) (string, bool)) (string, error) {
+	var (
+		b         strings.Builder
+		undefined []string
+	)
+	resolve := func(name string) string {
+		if lookup != nil {
+			if value, ok := lookup(name); ok {
+				return value
+			}
+		}
+		undefined = append(undefined, name)
+		return ""
+	}
+	expand := func(s string) string {
+		s = placeholder.ReplaceAllStringFunc(s, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			return resolve(match[2 : len(match)-1])
+		})
+		return template.ReplaceAllStringFunc(s, func(match string) string {
+			return resolve(template.FindStringSubmatch(match)[1])
+		})
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		end := i + 1
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end = strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i
+			}
+			b.WriteString(sql[i:end])
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end = strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 4
+			}
+			b.WriteString(sql[i:end])
+		case c == '\'' || c == '"' || c == '`':
+			end = strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 2
+			}
+			b.WriteString(expand(sql[i:end]))
+		case c == '$':
+			if loc := placeholder.FindStringIndex(sql[i:]); loc != nil && loc[0] == 0 {
+				end = i + loc[1]
+				b.WriteString(expand(sql[i:end]))
+			} else if tag := dollarTag(sql[i:]); tag != "" {
+				end = strings.Index(sql[i+len(tag):], tag)
+				if end < 0 {
+					end = len(sql)
+				} else {
+					end += i + 2*len(tag)
+				}
+				b.WriteString(sql[i:end])
+			} else {
+				b.WriteByte(c)
+			}
+		case c == '{':
+			if loc := template.FindStringIndex(sql[i:]); loc != nil && loc[0] == 0 {
+				end = i + loc[1]
+				b.WriteString(expand(sql[i:end]))
+			} else {
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+		i = end - 1
+	}
+
+	if len(undefined) > 0 {
+		return "", fmt.Errorf("undefined variable(s): %s", strings.Join(undefined, ", "))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,109 @@
+package sqlscript
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"schema": "app", "owner": "admin"}
+	lookup := func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name    string
+		sql     string
+		lookup  func(string) (string, bool)
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "no placeholders",
+			sql:    "SELECT 1;",
+			lookup: lookup,
+			want:   "SELECT 1;",
+		},
+		{
+			name:   "placeholders",
+			sql:    "CREATE TABLE ${schema}.users (id int);\nALTER TABLE ${schema}.users OWNER TO ${owner};",
+			lookup: lookup,
+			want:   "CREATE TABLE app.users (id int);\nALTER TABLE app.users OWNER TO admin;",
+		},
+		{
+			name:   "escaped placeholder",
+			sql:    "SELECT '$${schema}', '${schema}';",
+			lookup: lookup,
+			want:   "SELECT '${schema}', 'app';",
+		},
+		{
+			name:   "dollar quotes are not placeholders",
+			sql:    "DO $$ BEGIN PERFORM 1; END $$;",
+			lookup: lookup,
+			want:   "DO $$ BEGIN PERFORM 1; END $$;",
+		},
+		{
+			name:   "templates",
+			sql:    "GRANT USAGE ON SCHEMA {{ .schema }} TO {{.owner}};",
+			lookup: lookup,
+			want:   "GRANT USAGE ON SCHEMA app TO admin;",
+		},
+		{
+			name:   "comments are not expanded",
+			sql:    "-- set up ${schema} for ${unknown}\nCREATE SCHEMA ${schema}; /* {{ .unknown }}\n${unknown} */",
+			lookup: lookup,
+			want:   "-- set up ${schema} for ${unknown}\nCREATE SCHEMA app; /* {{ .unknown }}\n${unknown} */",
+		},
+		{
+			name:   "dollar-quoted bodies are not expanded",
+			sql:    "CREATE FUNCTION f() RETURNS text AS $body$ SELECT '${unknown}' $body$ LANGUAGE sql;\nDO $$ BEGIN RAISE NOTICE '${x}'; END $$;",
+			lookup: lookup,
+			want:   "CREATE FUNCTION f() RETURNS text AS $body$ SELECT '${unknown}' $body$ LANGUAGE sql;\nDO $$ BEGIN RAISE NOTICE '${x}'; END $$;",
+		},
+		{
+			name:   "comment markers in strings",
+			sql:    "SELECT '-- ${schema}', '/* ${owner}';",
+			lookup: lookup,
+			want:   "SELECT '-- app', '/* admin';",
+		},
+		{
+			name:   "escaped placeholder after a dollar quote",
+			sql:    "SELECT $$${schema}$$, $${schema};",
+			lookup: lookup,
+			want:   "SELECT $$${schema}$$, ${schema};",
+		},
+		{
+			name:   "braces that are not templates",
+			sql:    "SELECT '{{ not a template }}', '{\"a\": 1}';",
+			lookup: lookup,
+			want:   "SELECT '{{ not a template }}', '{\"a\": 1}';",
+		},
+		{
+			name:    "undefined variables",
+			sql:     "SELECT ${missing}, ${schema}, {{ .other }};",
+			lookup:  lookup,
+			wantErr: "undefined variable(s): missing, other",
+		},
+		{
+			name:    "no lookup",
+			sql:     "SELECT ${schema};",
+			wantErr: "undefined variable(s): schema",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.sql, tt.lookup)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Expand() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
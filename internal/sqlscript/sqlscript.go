@@ -0,0 +1,142 @@
+package sqlscript
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Statement struct {
+	Text string // comments removed, whitespace collapsed, for matching
+	Raw  string // as written, without the semicolon, for running
+	Line int
+}
+
+var (
+	triggerBody = regexp.MustCompile(`(?i)^\s*CREATE\s+(TEMP\s+|TEMPORARY\s+)?TRIGGER\b.*\bBEGIN\b`)
+	blockStart  = regexp.MustCompile(`(?i)\b(BEGIN|CASE)\b`)
+	blockEnd    = regexp.MustCompile(`(?i)\bEND\b`)
+)
+
+// Split splits a migration on semicolons, skipping the ones inside
+// comments, quoted strings and identifiers, postgres dollar-quoted bodies
+// and the BEGIN ... END body of sqlite triggers.
+func Split(sql string) []Statement {
+	var (
+		statements []Statement
+		current    strings.Builder
+		line       = 1
+		startLine  = 0
+		start      = 0
+	)
+
+	begin := func(i int) {
+		if startLine == 0 {
+			startLine, start = line, i
+		}
+	}
+	flush := func(end int) {
+		text := strings.Join(strings.Fields(current.String()), " ")
+		if text != "" {
+			raw := strings.TrimSpace(sql[start:min(end, len(sql))])
+			statements = append(statements, Statement{Text: text, Raw: raw, Line: startLine})
+		}
+		current.Reset()
+		startLine = 0
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\n':
+			line++
+			current.WriteByte(c)
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += end - 1
+			current.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 2
+			}
+			line += strings.Count(sql[i:i+2+end], "\n")
+			i += end + 3
+			current.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			begin(i)
+			end := i + 1
+			for end < len(sql) && sql[end] != c {
+				end++
+			}
+			line += strings.Count(sql[i:min(end, len(sql))], "\n")
+			current.WriteString(sql[i:min(end+1, len(sql))])
+			i = end
+		case c == '$':
+			tag := dollarTag(sql[i:])
+			if tag == "" {
+				current.WriteByte(c)
+				continue
+			}
+			begin(i)
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				end = len(sql) - i - len(tag)
+			} else {
+				end += len(tag)
+			}
+			body := sql[i:min(i+len(tag)+end, len(sql))]
+			line += strings.Count(body, "\n")
+			current.WriteString(body)
+			i += len(body) - 1
+		case c == ';' && inTrigger(current.String()):
+			current.WriteByte(c)
+		case c == ';':
+			flush(i)
+		default:
+			if c != ' ' && c != '\t' && c != '\r' {
+				begin(i)
+			}
+			current.WriteByte(c)
+		}
+	}
+	flush(len(sql))
+	return statements
+}
+
+// inTrigger reports whether stmt is a trigger whose body hasn't ended yet.
+func inTrigger(stmt string) bool {
+	if !triggerBody.MatchString(strings.ReplaceAll(stmt, "\n", " ")) {
+		return false
+	}
+	return len(blockStart.FindAllString(stmt, -1)) > len(blockEnd.FindAllString(stmt, -1))
+}
+
+// dollarTag returns the opening tag of a dollar-quoted string such as $$ or
+// $body$, or an empty string when s does not start one.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// HasAnnotation reports whether sql carries a "-- vagabond:<name>" comment
+// on a line of its own.
+func HasAnnotation(sql, name string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.TrimSpace(line) == "-- vagabond:"+name {
+			return true
+		}
+	}
+	return false
+}
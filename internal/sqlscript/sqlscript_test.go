@@ -0,0 +1,123 @@
+package sqlscript
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []Statement
+	}{
+		{
+			name: "statements and lines",
+			sql:  "CREATE TABLE a (id int);\n\nCREATE TABLE b (id int);\n",
+			want: []Statement{
+				{Text: "CREATE TABLE a (id int)", Raw: "CREATE TABLE a (id int)", Line: 1},
+				{Text: "CREATE TABLE b (id int)", Raw: "CREATE TABLE b (id int)", Line: 3},
+			},
+		},
+		{
+			name: "last statement without semicolon",
+			sql:  "SELECT 1;\nSELECT 2",
+			want: []Statement{
+				{Text: "SELECT 1", Raw: "SELECT 1", Line: 1},
+				{Text: "SELECT 2", Raw: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name: "literal whitespace is kept",
+			sql:  "INSERT INTO t VALUES ('a   b\nc; d');",
+			want: []Statement{
+				{Text: "INSERT INTO t VALUES ('a b c; d')", Raw: "INSERT INTO t VALUES ('a   b\nc; d')", Line: 1},
+			},
+		},
+		{
+			name: "quoted identifiers",
+			sql:  `SELECT "a;b" FROM t;`,
+			want: []Statement{
+				{Text: `SELECT "a;b" FROM t`, Raw: `SELECT "a;b" FROM t`, Line: 1},
+			},
+		},
+		{
+			name: "dollar-quoted body with comments",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  -- note; here\n  RETURN 1;\nEND\n$$ LANGUAGE plpgsql;\nSELECT f();",
+			want: []Statement{
+				{
+					Text: "CREATE FUNCTION f() RETURNS int AS $$ BEGIN -- note; here RETURN 1; END $$ LANGUAGE plpgsql",
+					Raw:  "CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  -- note; here\n  RETURN 1;\nEND\n$$ LANGUAGE plpgsql",
+					Line: 1,
+				},
+				{Text: "SELECT f()", Raw: "SELECT f()", Line: 7},
+			},
+		},
+		{
+			name: "tagged dollar quotes",
+			sql:  "DO $body$ BEGIN PERFORM 1; END $body$;",
+			want: []Statement{
+				{Text: "DO $body$ BEGIN PERFORM 1; END $body$", Raw: "DO $body$ BEGIN PERFORM 1; END $body$", Line: 1},
+			},
+		},
+		{
+			name: "sqlite trigger body",
+			sql:  "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO log VALUES (CASE WHEN NEW.x THEN 1 END);\n  DELETE FROM b;\nEND;\nSELECT 1;",
+			want: []Statement{
+				{
+					Text: "CREATE TRIGGER t AFTER INSERT ON a BEGIN INSERT INTO log VALUES (CASE WHEN NEW.x THEN 1 END); DELETE FROM b; END",
+					Raw:  "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO log VALUES (CASE WHEN NEW.x THEN 1 END);\n  DELETE FROM b;\nEND",
+					Line: 1,
+				},
+				{Text: "SELECT 1", Raw: "SELECT 1", Line: 5},
+			},
+		},
+		{
+			name: "transaction blocks are not triggers",
+			sql:  "BEGIN;\nSELECT 1;\nEND;",
+			want: []Statement{
+				{Text: "BEGIN", Raw: "BEGIN", Line: 1},
+				{Text: "SELECT 1", Raw: "SELECT 1", Line: 2},
+				{Text: "END", Raw: "END", Line: 3},
+			},
+		},
+		{
+			name: "comments",
+			sql:  "-- leading; comment\nSELECT 1 /* inline; */ + 2; -- trailing\n/* only; a comment */",
+			want: []Statement{
+				{Text: "SELECT 1 + 2", Raw: "SELECT 1 /* inline; */ + 2", Line: 2},
+			},
+		},
+		{
+			name: "only comments",
+			sql:  "-- nothing to run\n/* still nothing */\n",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasAnnotation(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"-- vagabond:no-transaction\nCREATE INDEX CONCURRENTLY i ON t (a);", true},
+		{"CREATE INDEX i ON t (a);\n  -- vagabond:no-transaction  \n", true},
+		{"-- vagabond:no-transaction please\nSELECT 1;", false},
+		{"SELECT '-- vagabond:no-transaction';", false},
+	}
+
+	for _, tt := range tests {
+		if got := HasAnnotation(tt.sql, "no-transaction"); got != tt.want {
+			t.Errorf("HasAnnotation(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/fleet"
+)
+
+type Options struct {
+	Pattern string
+	Query   string
+}
+
+func Schemas(ctx context.Context, cfg db.Config, opts Options) ([]string, error) {
+	driver, err := db.New(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	pg, ok := driver.(*db.Postgres)
+	if !ok {
+		return nil, fmt.Errorf("multi-tenant mode requires postgres")
+	}
+
+	names, err := pg.ListSchemas(ctx, opts.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenant schemas: %w", err)
+	}
+	return match(opts.Pattern, names)
+}
+
+// match keeps the schemas matching pattern, all of them without one.
+func match(pattern string, names []string) ([]string, error) {
+	if pattern == "" {
+		return names, nil
+	}
+
+	var schemas []string
+	for _, name := range names {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenant pattern %q: %w", pattern, err)
+		}
+		if ok {
+			schemas = append(schemas, name)
+		}
+	}
+	return schemas, nil
+}
+
+func Targets(ctx context.Context, cfg db.Config, opts Options) ([]fleet.Target, error) {
+	schemas, err := Schemas(ctx, cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return targets(cfg, schemas)
+}
+
+// targets gives every schema the configuration of its own database, which
+// only sees that schema.
+func targets(cfg db.Config, schemas []string) ([]fleet.Target, error) {
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("no tenant schemas matched")
+	}
+
+	targets := make([]fleet.Target, len(schemas))
+	for i, schema := range schemas {
+		tenantCfg := cfg
+		tenantCfg.DSN = withSearchPath(cfg.DSN, schema)
+		tenantCfg.Schemas = []string{schema}
+		tenantCfg.MigrationsSchema = schema
+		targets[i] = fleet.Target{Name: schema, Config: tenantCfg}
+	}
+	return targets, nil
+}
+
+// withSearchPath relies on lib/pq forwarding unknown connection parameters
+// to the server, so every pooled connection starts in the tenant schema.
+func withSearchPath(dsn, schema string) string {
+	searchPath := db.QuoteIdent(schema)
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		query := u.Query()
+		query.Set("search_path", searchPath)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return fmt.Sprintf("%s search_path=%s", dsn, searchPath)
+}
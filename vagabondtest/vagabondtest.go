@@ -0,0 +1,186 @@
+// Package vagabondtest builds throwaway SQLite databases migrated with
+// vagabond, for use in Go tests.
+//
+//	//go:embed migrations/*.sql
+//	var migrationFiles embed.FS
+//
+//	func TestUsers(t *testing.T) {
+//		db := vagabondtest.NewMigratedSQLite(t, migrationFiles)
+//		...
+//	}
+//
+// Migrations are read from the root of fsys, or from its migrations
+// directory when the root has none. Their ${name} placeholders are resolved
+// from environment variables, which tests can set with t.Setenv.
+package vagabondtest
+
+import (
+	"database/sql"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jxdones/vagabond/internal/db"
+	"github.com/jxdones/vagabond/internal/migrations"
+	"github.com/jxdones/vagabond/internal/sqlscript"
+)
+
+type database struct {
+	driver *db.SQLite
+	fsys   fs.FS
+}
+
+var (
+	mu        sync.Mutex
+	databases = map[*sql.DB]database{}
+)
+
+// NewSQLite returns an empty SQLite database in a temporary directory that
+// MigrateTo can move through the migrations in fsys. The database is closed
+// and removed when the test finishes.
+func NewSQLite(t testing.TB, fsys fs.FS) *sql.DB {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "vagabond.db"))
+	if err != nil {
+		t.Fatalf("vagabondtest: failed to open database: %v", err)
+	}
+	// a single connection keeps every statement on the same transaction
+	// state and avoids "database is locked" between the test and vagabond
+	conn.SetMaxOpenConns(1)
+
+	driver, err := db.NewSQLite(t.Context(), conn)
+	if err != nil {
+		conn.Close()
+		t.Fatalf("vagabondtest: failed to create migrations table: %v", err)
+	}
+
+	mu.Lock()
+	databases[conn] = database{driver: driver, fsys: fsys}
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(databases, conn)
+		mu.Unlock()
+		conn.Close()
+	})
+	return conn
+}
+
+// NewMigratedSQLite returns a temporary SQLite database with every migration
+// in fsys applied.
+func NewMigratedSQLite(t testing.TB, fsys fs.FS) *sql.DB {
+	t.Helper()
+
+	conn := NewSQLite(t, fsys)
+	MigrateTo(t, conn, "")
+	return conn
+}
+
+// MigrateTo applies or rolls back migrations until version is the latest
+// applied one, so data migrations can be tested against the schema they
+// start from. version is a migration's version or its full name, such as
+// 20240117093012 or 20240117093012_add_users; an empty version means the
+// latest migration. conn must come from NewSQLite or NewMigratedSQLite.
+func MigrateTo(t testing.TB, conn *sql.DB, version string) {
+	t.Helper()
+	ctx := t.Context()
+
+	mu.Lock()
+	d, ok := databases[conn]
+	mu.Unlock()
+	if !ok {
+		t.Fatalf("vagabondtest: database was not created by vagabondtest")
+	}
+
+	dir, ids := upMigrations(t, d.fsys)
+	target := len(ids) - 1
+	if version != "" {
+		target = slices.IndexFunc(ids, func(id string) bool {
+			name := strings.TrimSuffix(id, "_up")
+			return name == version || strings.HasPrefix(name, version+"_")
+		})
+		if target < 0 {
+			t.Fatalf("vagabondtest: unknown migration version %s", version)
+		}
+	}
+
+	applied, err := d.driver.GetAppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("vagabondtest: could not get applied migrations: %v", err)
+	}
+
+	for i := len(ids) - 1; i > target; i-- {
+		if !applied[ids[i]] {
+			continue
+		}
+		if migrations.IsIrreversible(readFile(t, d.fsys, path.Join(dir, ids[i]+".sql"))) {
+			t.Fatalf("vagabondtest: cannot rollback %s: the migration is marked irreversible", ids[i])
+		}
+		downFile := path.Join(dir, strings.TrimSuffix(ids[i], "_up")+"_down.sql")
+		down := readFile(t, d.fsys, downFile)
+		if migrations.IsIrreversible(down) {
+			t.Fatalf("vagabondtest: cannot rollback %s: the migration is marked irreversible", ids[i])
+		}
+		if err := d.driver.RevertMigration(ctx, ids[i], down); err != nil {
+			t.Fatalf("vagabondtest: failed to rollback %s: %v", ids[i], err)
+		}
+	}
+
+	for i := 0; i <= target; i++ {
+		if applied[ids[i]] {
+			continue
+		}
+		upFile := path.Join(dir, ids[i]+".sql")
+		if err := d.driver.ApplyMigration(ctx, ids[i], readFile(t, d.fsys, upFile)); err != nil {
+			t.Fatalf("vagabondtest: failed to apply %s: %v", ids[i], err)
+		}
+	}
+}
+
+// upMigrations returns the directory holding the migrations and the sorted
+// IDs of its up migrations.
+func upMigrations(t testing.TB, fsys fs.FS) (string, []string) {
+	t.Helper()
+
+	for _, dir := range []string{".", "migrations"} {
+		files, err := fs.Glob(fsys, path.Join(dir, "*_up.sql"))
+		if err != nil {
+			t.Fatalf("vagabondtest: failed to list migration files: %v", err)
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		ids := make([]string, len(files))
+		for i, file := range files {
+			ids[i] = strings.TrimSuffix(path.Base(file), ".sql")
+		}
+		sort.Slice(ids, func(i, j int) bool { return migrations.Less(ids[i], ids[j]) })
+		return dir, ids
+	}
+
+	t.Fatalf("vagabondtest: no migrations found")
+	return "", nil
+}
+
+func readFile(t testing.TB, fsys fs.FS, name string) string {
+	t.Helper()
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		t.Fatalf("vagabondtest: %v", err)
+	}
+	content, err := sqlscript.Expand(string(data), os.LookupEnv)
+	if err != nil {
+		t.Fatalf("vagabondtest: %s: %v", name, err)
+	}
+	return content
+}
@@ -0,0 +1,170 @@
+package vagabondtest
+
+import (
+	"database/sql"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var migrationFiles = fstest.MapFS{
+	"migrations/0001_add_users_up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+	"migrations/0001_add_users_down.sql": {Data: []byte("DROP TABLE users;")},
+	"migrations/0002_add_posts_up.sql":   {Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY);")},
+	"migrations/0002_add_posts_down.sql": {Data: []byte("DROP TABLE posts;")},
+	"migrations/0003_add_tags_up.sql":    {Data: []byte("CREATE TABLE ${tags_table} (id INTEGER PRIMARY KEY);")},
+	"migrations/0003_add_tags_down.sql":  {Data: []byte("DROP TABLE ${tags_table};")},
+}
+
+// tables lists the tables of conn, other than vagabond's and SQLite's.
+func tables(t *testing.T, conn *sql.DB) string {
+	t.Helper()
+	rows, err := conn.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'vagabond_%' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMigrateTo(t *testing.T) {
+	t.Setenv("tags_table", "tags")
+	conn := NewSQLite(t, migrationFiles)
+
+	steps := []struct {
+		version string
+		want    string
+	}{
+		{"0002", "posts users"},
+		{"0001_add_users", "users"},
+		{"", "posts tags users"},
+		{"0002_add_posts", "posts users"},
+		{"0003", "posts tags users"},
+	}
+
+	for _, step := range steps {
+		MigrateTo(t, conn, step.version)
+		if got := tables(t, conn); got != step.want {
+			t.Errorf("tables after MigrateTo(%q) = %s, want %s", step.version, got, step.want)
+		}
+	}
+}
+
+func TestNewMigratedSQLite(t *testing.T) {
+	t.Setenv("tags_table", "labels")
+	root := fstest.MapFS{
+		"0001_add_users_up.sql":   migrationFiles["migrations/0001_add_users_up.sql"],
+		"0002_add_tags_up.sql":    migrationFiles["migrations/0003_add_tags_up.sql"],
+		"migrations/0003_up.sql":  {Data: []byte("not read, the root has migrations")},
+		"0001_add_users_down.sql": migrationFiles["migrations/0001_add_users_down.sql"],
+	}
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"migrations directory", migrationFiles, "labels posts users"},
+		{"root", root, "labels users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tables(t, NewMigratedSQLite(t, tt.fsys)); got != tt.want {
+				t.Errorf("tables = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// fatal records the message of the Fatalf call ending a helper.
+type fatal struct {
+	testing.TB
+	message string
+}
+
+func (f *fatal) Fatalf(format string, args ...any) {
+	f.message = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// failure runs fn with a TB whose Fatalf is recorded instead of failing t,
+// and returns the message.
+func failure(t *testing.T, fn func(tb testing.TB)) string {
+	f := &fatal{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(f)
+	}()
+	<-done
+	return f.message
+}
+
+func TestMigrateToFailures(t *testing.T) {
+	irreversible := fstest.MapFS{
+		"0001_add_users_up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+		"0002_drop_name_up.sql":   {Data: []byte("CREATE TABLE old (id INTEGER);")},
+		"0002_drop_name_down.sql": {Data: []byte("-- vagabond:irreversible\n")},
+	}
+
+	tests := []struct {
+		name string
+		run  func(tb testing.TB)
+		want string
+	}{
+		{
+			name: "unknown version",
+			run:  func(tb testing.TB) { MigrateTo(tb, NewSQLite(tb, migrationFiles), "0042") },
+			want: "vagabondtest: unknown migration version 0042",
+		},
+		{
+			name: "irreversible migration",
+			run: func(tb testing.TB) {
+				MigrateTo(tb, NewMigratedSQLite(tb, irreversible), "0001")
+			},
+			want: "vagabondtest: cannot rollback 0002_drop_name_up: the migration is marked irreversible",
+		},
+		{
+			name: "undefined variable",
+			run:  func(tb testing.TB) { NewMigratedSQLite(tb, migrationFiles) },
+			want: "vagabondtest: migrations/0003_add_tags_up.sql: undefined variable(s): tags_table",
+		},
+		{
+			name: "no migrations",
+			run:  func(tb testing.TB) { NewMigratedSQLite(tb, fstest.MapFS{"README.md": {}}) },
+			want: "vagabondtest: no migrations found",
+		},
+		{
+			name: "foreign database",
+			run: func(tb testing.TB) {
+				conn, err := sql.Open("sqlite3", ":memory:")
+				if err != nil {
+					tb.Fatalf("%v", err)
+				}
+				defer conn.Close()
+				MigrateTo(tb, conn, "")
+			},
+			want: "vagabondtest: database was not created by vagabondtest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failure(t, tt.run); got != tt.want {
+				t.Errorf("failure = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}